@@ -0,0 +1,231 @@
+// Package client is a Go client for the public social-network API, meant
+// for services that talk to it through the gateway. It keeps the session in
+// a cookie jar like a browser would, sends the CSRF token with mutating
+// requests, retries idempotent requests and returns typed errors.
+//
+// The calls mirror openapi.yaml; tests check every request the client sends
+// against the spec.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiPrefix      = "/api/v1"
+	csrfHeader     = "X-CSRF-Token"
+	csrfCookieName = "csrf_token"
+)
+
+type Options struct {
+	// HTTPClient is used as a template: its transport and timeout are kept,
+	// but the client installs its own cookie jar. Defaults to a client with
+	// a 30s timeout.
+	HTTPClient *http.Client
+	// Token, if set, is sent as "Authorization: Bearer" instead of relying
+	// on the session cookie, e.g. for a token obtained elsewhere. Logging in
+	// replaces it.
+	Token string
+	// MaxRetries is the number of extra attempts for GET and HEAD requests
+	// that failed with a network error, 502, 503 or 504.
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	opts    Options
+
+	// token is the session token. It is only sent as a bearer token when
+	// the client was created with one; otherwise the cookie jar carries it.
+	token  string
+	bearer bool
+}
+
+// New returns a client for the gateway at baseURL, e.g.
+// "https://api.example.com".
+func New(baseURL string, opts Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+	if opts.MaxRetries < 0 {
+		return nil, errors.New("max retries must not be negative")
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if opts.HTTPClient != nil {
+		copied := *opts.HTTPClient
+		httpClient = &copied
+	}
+	httpClient.Jar = jar
+
+	return &Client{baseURL: base, http: httpClient, opts: opts, token: opts.Token, bearer: opts.Token != ""}, nil
+}
+
+// Token returns the current session token, from the last login or Options.
+func (c *Client) Token() string {
+	return c.token
+}
+
+// request describes one API call: path is already escaped, body, if not
+// nil, is sent as JSON and a JSON response is decoded into out.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        interface{}
+	contentType string
+	header      http.Header
+	out         interface{}
+}
+
+// do sends req, retrying it if allowed. Responses with an error status are
+// returned as *Error; the body of the returned response is already closed.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		payload, err = json.Marshal(req.body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		if req.contentType == "" {
+			req.contentType = "application/json"
+		}
+	}
+
+	path, err := url.PathUnescape(req.path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request path: %w", err)
+	}
+	// RawPath keeps escaped slashes in path segments such as usernames.
+	target := *c.baseURL
+	target.Path += path
+	target.RawPath = c.baseURL.EscapedPath() + req.path
+	target.RawQuery = req.query.Encode()
+
+	attempts := 1
+	if retryable(req.method) {
+		attempts += c.opts.MaxRetries
+	}
+
+	for attempt := 1; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, target.String(), bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range req.header {
+			httpReq.Header[name] = values
+		}
+		httpReq.Header.Set("Accept", "application/json")
+		if req.contentType != "" {
+			httpReq.Header.Set("Content-Type", req.contentType)
+		}
+		c.authenticate(httpReq)
+
+		resp, err := c.http.Do(httpReq)
+		failed := err != nil || unavailable(resp.StatusCode)
+		if !failed || attempt >= attempts || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			return resp, c.decode(resp, req.out)
+		}
+
+		delay := c.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = after
+			}
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// authenticate adds the bearer token if there is one, and otherwise echoes
+// the CSRF cookie set at login on requests that change state.
+func (c *Client) authenticate(req *http.Request) {
+	if c.bearer && c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		return
+	}
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return
+	}
+	for _, cookie := range c.http.Jar.Cookies(req.URL) {
+		if cookie.Name == csrfCookieName {
+			req.Header.Set(csrfHeader, cookie.Value)
+		}
+	}
+}
+
+func (c *Client) decode(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return newError(resp)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// backoff doubles the base delay with every attempt and applies full
+// jitter, like the gateway does towards its upstreams.
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.opts.RetryBackoff << (attempt - 1)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+func retryable(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func unavailable(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// retryAfter reads a Retry-After header given in seconds, as the gateway
+// sends it while its circuit breaker is open.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by *Error with errors.Is, e.g.
+// errors.Is(err, client.ErrNotFound).
+var (
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrUnavailable        = errors.New("service unavailable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:         ErrBadRequest,
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusConflict:           ErrConflict,
+	http.StatusPreconditionFailed: ErrPreconditionFailed,
+	http.StatusTooManyRequests:    ErrTooManyRequests,
+	http.StatusBadGateway:         ErrUnavailable,
+	http.StatusServiceUnavailable: ErrUnavailable,
+	http.StatusGatewayTimeout:     ErrUnavailable,
+}
+
+// Error is returned for responses with a 4xx or 5xx status.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RequestID identifies the request in the service logs.
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("api error %d", e.StatusCode)
+	}
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// newError reads the message from the plain-text bodies of user-service
+// or the JSON bodies the gateway answers with when the upstream failed.
+func newError(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+
+	var gatewayErr struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(body, &gatewayErr) == nil && gatewayErr.Error != "" {
+		apiErr.Message = gatewayErr.Error
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = gatewayErr.RequestID
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"social-network/user-service/models"
+)
+
+// SignUp registers a new account. It does not log in.
+func (c *Client) SignUp(ctx context.Context, req *models.SignInRequest) (*models.AuthResponse, error) {
+	var response models.AuthResponse
+	_, err := c.do(ctx, request{method: "POST", path: apiPrefix + "/auth/signin", body: req, out: &response})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Login starts a session. If the account has two-factor authentication
+// enabled, the response has TwoFactorRequired set and the session only
+// starts with VerifyTwoFactor.
+func (c *Client) Login(ctx context.Context, username, password string) (*models.AuthResponse, error) {
+	var response models.AuthResponse
+	_, err := c.do(ctx, request{
+		method: "POST",
+		path:   apiPrefix + "/auth/login",
+		body:   &models.LoginRequest{Username: username, Password: password},
+		out:    &response,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !response.TwoFactorRequired {
+		c.token = response.Token
+	}
+	return &response, nil
+}
+
+// VerifyTwoFactor completes a login with a TOTP or recovery code.
+func (c *Client) VerifyTwoFactor(ctx context.Context, challengeToken, code string) (*models.AuthResponse, error) {
+	var response models.AuthResponse
+	_, err := c.do(ctx, request{
+		method: "POST",
+		path:   apiPrefix + "/auth/2fa/verify",
+		body:   &models.TwoFactorLoginRequest{ChallengeToken: challengeToken, Code: code},
+		out:    &response,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.token = response.Token
+	return &response, nil
+}
+
+// Logout ends the current session.
+func (c *Client) Logout(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: "POST", path: apiPrefix + "/auth/logout"})
+	if err != nil {
+		return err
+	}
+	c.token = ""
+	return nil
+}
+
+// Me returns the profile of the logged-in user and its ETag, which
+// PatchMe takes to detect concurrent changes.
+func (c *Client) Me(ctx context.Context) (*models.User, string, error) {
+	var user models.User
+	resp, err := c.do(ctx, request{method: "GET", path: apiPrefix + "/users/me", out: &user})
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, resp.Header.Get("ETag"), nil
+}
+
+// UpdateMe replaces the editable fields of the own profile.
+func (c *Client) UpdateMe(ctx context.Context, req *models.UpdateUserRequest) (*models.User, error) {
+	var user models.User
+	_, err := c.do(ctx, request{method: "PUT", path: apiPrefix + "/users/me", body: req, out: &user})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PatchMe applies a JSON Merge Patch to the own profile: fields missing
+// from patch are kept and nil values clear them. If etag is not empty the
+// update fails with ErrPreconditionFailed when the profile changed since.
+// It returns the updated profile and its new ETag.
+func (c *Client) PatchMe(ctx context.Context, patch map[string]interface{}, etag string) (*models.User, string, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+
+	var user models.User
+	resp, err := c.do(ctx, request{
+		method:      "PATCH",
+		path:        apiPrefix + "/users/me",
+		body:        patch,
+		contentType: "application/merge-patch+json",
+		header:      header,
+		out:         &user,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, resp.Header.Get("ETag"), nil
+}
+
+// ChangePassword changes the password. Other sessions are logged out.
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	_, err := c.do(ctx, request{
+		method: "PUT",
+		path:   apiPrefix + "/users/password",
+		body:   &models.ChangePasswordRequest{CurrentPassword: currentPassword, NewPassword: newPassword},
+	})
+	return err
+}
+
+// DeleteAccount schedules the account for deletion and ends all sessions.
+func (c *Client) DeleteAccount(ctx context.Context, password string) (*models.DeleteAccountResponse, error) {
+	var response models.DeleteAccountResponse
+	_, err := c.do(ctx, request{
+		method: "DELETE",
+		path:   apiPrefix + "/users/me",
+		body:   &models.DeleteAccountRequest{Password: password},
+		out:    &response,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.token = ""
+	return &response, nil
+}
+
+// GetUser returns the public profile of a user.
+func (c *Client) GetUser(ctx context.Context, username string) (*models.UserPublic, error) {
+	var user models.UserPublic
+	_, err := c.do(ctx, request{method: "GET", path: apiPrefix + "/users/" + url.PathEscape(username), out: &user})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SearchUsers returns one page of users matching query. Pass the
+// NextCursor of the previous page to get the next one; limit 0 uses the
+// server default.
+func (c *Client) SearchUsers(ctx context.Context, query, cursor string, limit int) (*models.UserSearchResponse, error) {
+	params := url.Values{}
+	if query != "" {
+		params.Set("query", query)
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var response models.UserSearchResponse
+	_, err := c.do(ctx, request{method: "GET", path: apiPrefix + "/users", query: params, out: &response})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// LookupUsers resolves up to 100 usernames and public IDs in one request.
+func (c *Client) LookupUsers(ctx context.Context, req *models.BatchUserLookupRequest) (*models.BatchUserLookupResponse, error) {
+	var response models.BatchUserLookupResponse
+	_, err := c.do(ctx, request{method: "POST", path: apiPrefix + "/users/batch", body: req, out: &response})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
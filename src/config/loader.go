@@ -0,0 +1,285 @@
+// Package config loads typed service configuration. Values come from, in
+// increasing order of precedence: `default` struct tags, an optional YAML
+// file, environment variables and command-line flags.
+//
+// Every leaf field is described by tags:
+//
+//	Port int `yaml:"port" env:"SERVER_PORT" flag:"port" default:"8000" usage:"..."`
+//
+// Fields tagged `secret:"true"` are masked by Redact.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable that can point to a YAML
+// file when the -config flag is not given.
+const ConfigFileEnv = "CONFIG_FILE"
+
+const redactedValue = "[REDACTED]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+type field struct {
+	path   []string
+	value  reflect.Value
+	env    string
+	flag   string
+	def    string
+	usage  string
+	secret bool
+}
+
+func fields(v reflect.Value, prefix []string) []field {
+	var result []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		name := strings.SplitN(structField.Tag.Get("yaml"), ",", 2)[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := append(append([]string{}, prefix...), name)
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct {
+			result = append(result, fields(value, path)...)
+			continue
+		}
+
+		result = append(result, field{
+			path:   path,
+			value:  value,
+			env:    structField.Tag.Get("env"),
+			flag:   structField.Tag.Get("flag"),
+			def:    structField.Tag.Get("default"),
+			usage:  structField.Tag.Get("usage"),
+			secret: structField.Tag.Get("secret") == "true",
+		})
+	}
+	return result
+}
+
+func (f field) name() string {
+	return strings.Join(f.path, ".")
+}
+
+// Load fills cfg, which must be a pointer to a struct. name is used as the
+// program name in flag usage output; args are the command-line arguments
+// without the program name.
+func Load(cfg interface{}, name string, args []string) error {
+	_, err := LoadArgs(cfg, name, args)
+	return err
+}
+
+// LoadArgs is Load for commands that take arguments after the flags, such
+// as snctl subcommands; those are returned.
+func LoadArgs(cfg interface{}, name string, args []string) ([]string, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("config: Load needs a pointer to a struct")
+	}
+	all := fields(v.Elem(), nil)
+
+	for _, f := range all {
+		if f.def == "" {
+			continue
+		}
+		if err := setValue(f.value, f.def); err != nil {
+			return nil, fmt.Errorf("config: default for %s: %w", f.name(), err)
+		}
+	}
+
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flagSet.String("config", "", "path to a YAML configuration file")
+	flagValues := map[string]string{}
+	for _, f := range all {
+		if f.flag == "" {
+			continue
+		}
+		flagSet.Var(&pendingFlag{field: f, values: flagValues}, f.flag, f.usage)
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	if path != "" {
+		if err := loadYAML(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range all {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(f.env)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", f.env, err)
+		}
+	}
+
+	for _, f := range all {
+		raw, ok := flagValues[f.flag]
+		if !ok {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			return nil, fmt.Errorf("config: -%s: %w", f.flag, err)
+		}
+	}
+
+	return flagSet.Args(), nil
+}
+
+func loadYAML(cfg interface{}, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// pendingFlag records flag values so they can be applied after the YAML
+// file and environment, which are only known once flags are parsed.
+type pendingFlag struct {
+	field  field
+	values map[string]string
+}
+
+func (p *pendingFlag) String() string {
+	if p == nil || p.values == nil {
+		return ""
+	}
+	return p.values[p.field.flag]
+}
+
+func (p *pendingFlag) Set(raw string) error {
+	probe := reflect.New(p.field.value.Type()).Elem()
+	if err := setValue(probe, raw); err != nil {
+		return err
+	}
+	p.values[p.field.flag] = raw
+	return nil
+}
+
+func (p *pendingFlag) IsBoolFlag() bool {
+	return p.field.value.Kind() == reflect.Bool
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Redact returns the configuration as nested maps keyed by YAML names, with
+// non-empty secrets masked and durations formatted, ready to be logged or
+// served as JSON.
+func Redact(cfg interface{}) map[string]interface{} {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	result := map[string]interface{}{}
+	for _, f := range fields(v, nil) {
+		node := result
+		for _, key := range f.path[:len(f.path)-1] {
+			child, ok := node[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[key] = child
+			}
+			node = child
+		}
+
+		var value interface{}
+		switch {
+		case f.secret && !f.value.IsZero():
+			value = redactedValue
+		case f.value.Type() == durationType:
+			value = time.Duration(f.value.Int()).String()
+		default:
+			value = f.value.Interface()
+		}
+		node[f.path[len(f.path)-1]] = value
+	}
+	return result
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"fmt"
+	"io"
+
+	"social-network/logging"
+)
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" default:"info" usage:"debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"json" usage:"json or text"`
+}
+
+func (l LogConfig) Validate() error {
+	if _, err := logging.New(io.Discard, l.Format, l.Level); err != nil {
+		return fmt.Errorf("log: %w", err)
+	}
+	return nil
+}
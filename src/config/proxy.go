@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type Proxy struct {
+	Port            int            `yaml:"port" env:"PORT" flag:"port" default:"8080" usage:"HTTP listen port"`
+	InternalPort    int            `yaml:"internal_port" env:"INTERNAL_PORT" flag:"internal-port" default:"9090" usage:"listen port for /metrics and detailed /readyz; keep it off the public network"`
+	UserServiceURL  string         `yaml:"user_service_url" env:"USER_SERVICE_URL" flag:"user-service-url" default:"http://user-service:8000" usage:"base URL of the user service"`
+	ShutdownTimeout time.Duration  `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"15s"`
+	DrainDelay      time.Duration  `yaml:"drain_delay" env:"SERVER_DRAIN_DELAY" default:"5s" usage:"how long /readyz fails before the server stops accepting connections"`
+	LegacySunset    string         `yaml:"legacy_sunset" env:"LEGACY_API_SUNSET" usage:"date (YYYY-MM-DD) announced for the removal of unversioned paths"`
+	TLS             TLSConfig      `yaml:"tls"`
+	Upstream        UpstreamConfig `yaml:"upstream"`
+	CORS            CORSConfig     `yaml:"cors"`
+	Log             LogConfig      `yaml:"log"`
+	Tracing         TracingConfig  `yaml:"tracing"`
+}
+
+type UpstreamConfig struct {
+	DialTimeout           time.Duration `yaml:"dial_timeout" env:"UPSTREAM_DIAL_TIMEOUT" default:"2s"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout" env:"UPSTREAM_RESPONSE_HEADER_TIMEOUT" default:"10s" usage:"how long to wait for the upstream to start responding"`
+	MaxRetries            int           `yaml:"max_retries" env:"UPSTREAM_MAX_RETRIES" default:"2" usage:"extra attempts for GET and HEAD requests"`
+	RetryBackoff          time.Duration `yaml:"retry_backoff" env:"UPSTREAM_RETRY_BACKOFF" default:"100ms"`
+	BreakerFailures       int           `yaml:"breaker_failures" env:"UPSTREAM_BREAKER_FAILURES" default:"5" usage:"consecutive failures that open the circuit"`
+	BreakerOpenTimeout    time.Duration `yaml:"breaker_open_timeout" env:"UPSTREAM_BREAKER_OPEN_TIMEOUT" default:"30s"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE" flag:"tls-cert" usage:"serve HTTPS with this certificate (PEM, full chain)"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE" flag:"tls-key"`
+	// ReloadInterval is how often the files are checked for a renewed
+	// certificate; SIGHUP reloads them immediately.
+	ReloadInterval        time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" default:"1m" usage:"0 disables polling"`
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age" env:"HSTS_MAX_AGE" default:"8760h" usage:"0 disables Strict-Transport-Security"`
+	HSTSIncludeSubdomains bool          `yaml:"hsts_include_subdomains" env:"HSTS_INCLUDE_SUBDOMAINS" default:"false"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+func (t TLSConfig) Validate() error {
+	var problems []error
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, errors.New("tls.cert_file and tls.key_file must be set together"))
+	}
+	for _, file := range []string{t.CertFile, t.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Errorf("tls: %v", err))
+		}
+	}
+	if t.ReloadInterval < 0 || t.HSTSMaxAge < 0 {
+		problems = append(problems, errors.New("tls.reload_interval and tls.hsts_max_age must not be negative"))
+	}
+	return errors.Join(problems...)
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" flag:"cors-allowed-origins" usage:"comma-separated origins allowed to call the API from a browser"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" default:"true" usage:"let browsers send the session cookie cross-origin"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" default:"10m" usage:"how long browsers may cache preflight responses"`
+}
+
+func (c CORSConfig) Validate() error {
+	var problems []error
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				problems = append(problems, errors.New("cors.allowed_origins cannot contain * when cors.allow_credentials is enabled"))
+			}
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || strings.TrimSuffix(parsed.Path, "/") != "" {
+			problems = append(problems, fmt.Errorf("cors origin %q must look like https://host[:port]", origin))
+		}
+	}
+	if c.MaxAge < 0 || c.MaxAge > 24*time.Hour {
+		problems = append(problems, errors.New("cors.max_age must be between 0 and 24h"))
+	}
+	return errors.Join(problems...)
+}
+
+// LegacySunsetTime returns the zero time if no sunset is announced.
+func (c *Proxy) LegacySunsetTime() time.Time {
+	sunset, _ := time.Parse(time.DateOnly, c.LegacySunset)
+	return sunset
+}
+
+func LoadProxy(args []string) (*Proxy, error) {
+	var cfg Proxy
+	if err := Load(&cfg, "proxy-service", args); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Proxy) Validate() error {
+	var problems []error
+
+	if !validPort(c.Port) {
+		problems = append(problems, errors.New("port must be between 1 and 65535"))
+	}
+	if !validPort(c.InternalPort) || c.InternalPort == c.Port {
+		problems = append(problems, errors.New("internal_port must be between 1 and 65535 and differ from port"))
+	}
+
+	target, err := url.Parse(c.UserServiceURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		problems = append(problems, fmt.Errorf("user_service_url %q must be an absolute http(s) URL", c.UserServiceURL))
+	}
+
+	if c.ShutdownTimeout <= 0 || c.DrainDelay < 0 {
+		problems = append(problems, errors.New("shutdown_timeout must be positive and drain_delay must not be negative"))
+	}
+	if c.LegacySunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.LegacySunset); err != nil {
+			problems = append(problems, fmt.Errorf("legacy_sunset %q must be a YYYY-MM-DD date", c.LegacySunset))
+		}
+	}
+	if err := c.TLS.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if c.Upstream.DialTimeout <= 0 || c.Upstream.ResponseHeaderTimeout <= 0 {
+		problems = append(problems, errors.New("upstream timeouts must be positive"))
+	}
+	if c.Upstream.MaxRetries < 0 || c.Upstream.MaxRetries > 5 {
+		problems = append(problems, errors.New("upstream.max_retries must be between 0 and 5"))
+	}
+	if c.Upstream.RetryBackoff < 0 {
+		problems = append(problems, errors.New("upstream.retry_backoff must not be negative"))
+	}
+	if c.Upstream.BreakerFailures < 1 {
+		problems = append(problems, errors.New("upstream.breaker_failures must be at least 1"))
+	}
+	if c.Upstream.BreakerOpenTimeout < time.Second {
+		problems = append(problems, errors.New("upstream.breaker_open_timeout must be at least 1s"))
+	}
+
+	if err := c.CORS.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if err := c.Log.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	return errors.Join(problems...)
+}
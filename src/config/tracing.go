@@ -0,0 +1,41 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"social-network/tracing"
+)
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" default:"none" usage:"none, stdout, file or otlp"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP/HTTP collector URL, e.g. http://otel-collector:4318"`
+	File        string  `yaml:"file" env:"TRACING_FILE" default:"traces.json" usage:"output file of the file exporter"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" usage:"fraction of new traces to record"`
+}
+
+func (t TracingConfig) Validate() error {
+	var problems []error
+	switch t.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	case tracing.ExporterFile:
+		if t.File == "" {
+			problems = append(problems, errors.New("tracing.file is required for the file exporter"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("tracing.exporter %q must be none, stdout, file or otlp", t.Exporter))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		problems = append(problems, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	return errors.Join(problems...)
+}
+
+func (t TracingConfig) Options() tracing.Options {
+	return tracing.Options{
+		Exporter:    t.Exporter,
+		Endpoint:    t.Endpoint,
+		File:        t.File,
+		SampleRatio: t.SampleRatio,
+	}
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+type UserService struct {
+	Server         ServerConfig   `yaml:"server"`
+	Database       DatabaseConfig `yaml:"database"`
+	Session        SessionConfig  `yaml:"session"`
+	Media          MediaConfig    `yaml:"media"`
+	Phone          PhoneConfig    `yaml:"phone"`
+	Password       PasswordConfig `yaml:"password"`
+	Log            LogConfig      `yaml:"log"`
+	Tracing        TracingConfig  `yaml:"tracing"`
+	OpenAPI        OpenAPIConfig  `yaml:"openapi"`
+	BootstrapAdmin string         `yaml:"bootstrap_admin" env:"BOOTSTRAP_ADMIN" flag:"bootstrap-admin" usage:"username to grant the admin role at startup"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port" env:"SERVER_PORT" flag:"port" default:"8000" usage:"HTTP listen port"`
+	InternalPort    int           `yaml:"internal_port" env:"SERVER_INTERNAL_PORT" flag:"internal-port" default:"9091" usage:"listen port for /metrics and detailed /readyz; keep it off the public network"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" default:"15s"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"15s"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"60s"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"15s"`
+	DrainDelay      time.Duration `yaml:"drain_delay" env:"SERVER_DRAIN_DELAY" default:"5s" usage:"how long /readyz fails before the server stops accepting connections"`
+}
+
+type DatabaseConfig struct {
+	Host            string        `yaml:"host" env:"DB_HOST" flag:"db-host" default:"postgres"`
+	Port            int           `yaml:"port" env:"DB_PORT" flag:"db-port" default:"5432"`
+	User            string        `yaml:"user" env:"DB_USER" flag:"db-user" default:"postgres"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name            string        `yaml:"name" env:"DB_NAME" flag:"db-name" default:"socialnetwork"`
+	SSLMode         string        `yaml:"sslmode" env:"DB_SSLMODE" default:"disable" usage:"libpq sslmode; use verify-full in production"`
+	SSLRootCert     string        `yaml:"sslrootcert" env:"DB_SSLROOTCERT" usage:"CA certificate used to verify the server"`
+	SSLCert         string        `yaml:"sslcert" env:"DB_SSLCERT" usage:"client certificate"`
+	SSLKey          string        `yaml:"sslkey" env:"DB_SSLKEY" usage:"client certificate key"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"20"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"5"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"1h"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" default:"5s"`
+	// StatementTimeout is sent as the statement_timeout run-time parameter
+	// of every connection, which is equivalent to SET statement_timeout.
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT" default:"5s" usage:"0 disables the limit"`
+	// ConnectRetries is how many attempts are made before the service starts
+	// in degraded mode and keeps reconnecting in the background.
+	ConnectRetries    int           `yaml:"connect_retries" env:"DB_CONNECT_RETRIES" default:"5"`
+	ReconnectMaxDelay time.Duration `yaml:"reconnect_max_delay" env:"DB_RECONNECT_MAX_DELAY" default:"30s"`
+	ReplicaHost       string        `yaml:"replica_host" env:"DB_REPLICA_HOST" usage:"read replica for lag-tolerant reads; same credentials as the primary"`
+	ReplicaPort       int           `yaml:"replica_port" env:"DB_REPLICA_PORT" default:"5432"`
+}
+
+type SessionConfig struct {
+	TTL             time.Duration `yaml:"ttl" env:"SESSION_TTL" default:"24h"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"CLEANUP_INTERVAL" default:"1h"`
+}
+
+type MediaConfig struct {
+	Root    string `yaml:"root" env:"MEDIA_ROOT" flag:"media-root" default:"./media"`
+	BaseURL string `yaml:"base_url" env:"MEDIA_BASE_URL" default:"/media"`
+}
+
+type PhoneConfig struct {
+	DefaultRegion string `yaml:"default_region" env:"PHONE_DEFAULT_REGION" default:"RU"`
+	RequireUnique bool   `yaml:"require_unique" env:"PHONE_REQUIRE_UNIQUE" default:"false"`
+	// SMSProvider is required: "log" delivers nothing and must only be
+	// chosen on purpose, in development.
+	SMSProvider     string        `yaml:"sms_provider" env:"SMS_PROVIDER" flag:"sms-provider" usage:"webhook, or log in development"`
+	SMSWebhookURL   string        `yaml:"sms_webhook_url" env:"SMS_WEBHOOK_URL" usage:"gateway the webhook provider posts messages to"`
+	SMSWebhookToken string        `yaml:"sms_webhook_token" env:"SMS_WEBHOOK_TOKEN" secret:"true"`
+	SMSTimeout      time.Duration `yaml:"sms_timeout" env:"SMS_TIMEOUT" default:"10s"`
+}
+
+// OpenAPIConfig turns on checking of requests and responses against the
+// spec. It buffers every response, so it is meant for development and tests.
+type OpenAPIConfig struct {
+	Validate bool   `yaml:"validate" env:"OPENAPI_VALIDATE" flag:"openapi-validate" default:"false" usage:"reject requests and log responses that don't match the spec"`
+	SpecFile string `yaml:"spec_file" env:"OPENAPI_SPEC" default:"openapi.yaml"`
+}
+
+type PasswordConfig struct {
+	HashAlgorithm       string `yaml:"hash_algorithm" env:"PASSWORD_HASH_ALGORITHM" default:"bcrypt"`
+	BcryptCost          int    `yaml:"bcrypt_cost" env:"BCRYPT_COST" default:"12"`
+	Argon2MemoryKB      uint32 `yaml:"argon2_memory_kb" env:"ARGON2_MEMORY_KB" default:"65536"`
+	Argon2Iterations    uint32 `yaml:"argon2_iterations" env:"ARGON2_ITERATIONS" default:"3"`
+	Argon2Parallelism   uint8  `yaml:"argon2_parallelism" env:"ARGON2_PARALLELISM" default:"2"`
+	MinLength           int    `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" default:"8"`
+	MinCharacterClasses int    `yaml:"min_character_classes" env:"PASSWORD_MIN_CHARACTER_CLASSES" default:"2"`
+	ForbidPersonalInfo  bool   `yaml:"forbid_personal_info" env:"PASSWORD_FORBID_PERSONAL_INFO" default:"true"`
+	BreachedListFile    string `yaml:"breached_list_file" env:"BREACHED_PASSWORDS_FILE"`
+}
+
+func LoadUserService(args []string) (*UserService, error) {
+	var cfg UserService
+	if err := Load(&cfg, "user-service", args); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadUserServiceCommand loads the user-service configuration for a tool
+// working on the same database, such as snctl. Configuration flags come
+// before the command; the command and its arguments are returned.
+func LoadUserServiceCommand(name string, args []string) (*UserService, []string, error) {
+	var cfg UserService
+	rest, err := LoadArgs(&cfg, name, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, rest, nil
+}
+
+// Validate reports every invalid setting at once. Settings that only the
+// service packages can judge, such as hashing parameters, are checked by
+// user-service/setup.
+func (c *UserService) Validate() error {
+	var problems []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port must be between 1 and 65535")
+	check(validPort(c.Server.InternalPort) && c.Server.InternalPort != c.Server.Port,
+		"server.internal_port must be between 1 and 65535 and differ from server.port")
+	check(c.Server.ReadTimeout > 0 && c.Server.WriteTimeout > 0 && c.Server.IdleTimeout > 0,
+		"server timeouts must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay must not be negative")
+
+	check(c.Database.Host != "", "database.host is required")
+	check(validPort(c.Database.Port), "database.port must be between 1 and 65535")
+	check(c.Database.User != "", "database.user is required")
+	check(c.Database.Password != "", "database.password is required (DB_PASSWORD)")
+	check(c.Database.Name != "", "database.name is required")
+	check(validSSLModes[c.Database.SSLMode], "database.sslmode %q is not supported; use disable, require, verify-ca or verify-full", c.Database.SSLMode)
+	check(c.Database.MaxOpenConns >= 1, "database.max_open_conns must be at least 1")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns must be between 0 and max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time must not be negative")
+	check(c.Database.ConnectTimeout >= time.Second, "database.connect_timeout must be at least 1s")
+	check(c.Database.StatementTimeout >= 0, "database.statement_timeout must not be negative")
+	check(c.Database.ConnectRetries >= 1, "database.connect_retries must be at least 1")
+	check(c.Database.ReconnectMaxDelay >= time.Second, "database.reconnect_max_delay must be at least 1s")
+	check(c.Database.ReplicaHost == "" || validPort(c.Database.ReplicaPort), "database.replica_port must be between 1 and 65535")
+	for _, file := range c.Database.tlsFiles() {
+		if file.path != "" {
+			_, err := os.Stat(file.path)
+			check(err == nil, "database.%s: %v", file.key, err)
+		}
+	}
+	check((c.Database.SSLCert == "") == (c.Database.SSLKey == ""), "database.sslcert and database.sslkey must be set together")
+
+	if c.OpenAPI.Validate {
+		_, err := os.Stat(c.OpenAPI.SpecFile)
+		check(err == nil, "openapi.spec_file: %v", err)
+	}
+
+	check(c.Session.TTL >= time.Minute, "session.ttl must be at least 1m")
+	check(c.Session.CleanupInterval >= time.Minute, "session.cleanup_interval must be at least 1m")
+
+	check(c.Media.Root != "", "media.root is required")
+
+	if err := c.Log.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	switch c.Phone.SMSProvider {
+	case "webhook":
+		webhook, err := url.Parse(c.Phone.SMSWebhookURL)
+		check(err == nil && (webhook.Scheme == "http" || webhook.Scheme == "https") && webhook.Host != "",
+			"phone.sms_webhook_url must be an absolute http(s) URL (SMS_WEBHOOK_URL)")
+	case "log":
+	case "":
+		problems = append(problems, errors.New(`phone.sms_provider is required (SMS_PROVIDER): "webhook", or "log" to skip sending in development`))
+	default:
+		problems = append(problems, fmt.Errorf("phone.sms_provider %q is not supported", c.Phone.SMSProvider))
+	}
+	check(c.Phone.SMSTimeout > 0, "phone.sms_timeout must be positive")
+
+	check(c.Password.MinLength >= 1, "password.min_length must be at least 1")
+	check(c.Password.MinCharacterClasses >= 0 && c.Password.MinCharacterClasses <= 4,
+		"password.min_character_classes must be between 0 and 4")
+
+	return errors.Join(problems...)
+}
+
+// DSN builds a libpq connection URL, escaping credentials as needed.
+func (d DatabaseConfig) DSN() string {
+	return d.dsn(d.Host, d.Port)
+}
+
+type tlsFile struct {
+	key, path string
+}
+
+func (d DatabaseConfig) tlsFiles() []tlsFile {
+	return []tlsFile{
+		{"sslrootcert", d.SSLRootCert},
+		{"sslcert", d.SSLCert},
+		{"sslkey", d.SSLKey},
+	}
+}
+
+// ReplicaDSN returns an empty string when no replica is configured.
+func (d DatabaseConfig) ReplicaDSN() string {
+	if d.ReplicaHost == "" {
+		return ""
+	}
+	return d.dsn(d.ReplicaHost, d.ReplicaPort)
+}
+
+func (d DatabaseConfig) dsn(host string, port int) string {
+	params := url.Values{
+		"sslmode":         {d.SSLMode},
+		"connect_timeout": {strconv.Itoa(int(d.ConnectTimeout / time.Second))},
+	}
+	if d.StatementTimeout > 0 {
+		// lib/pq passes unknown keys on as run-time parameters.
+		params.Set("statement_timeout", strconv.FormatInt(d.StatementTimeout.Milliseconds(), 10))
+	}
+	for _, file := range d.tlsFiles() {
+		if file.path != "" {
+			params.Set(file.key, file.path)
+		}
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:     "/" + d.Name,
+		RawQuery: params.Encode(),
+	}
+	return dsn.String()
+}
+
+// validSSLModes are the sslmodes lib/pq implements. It rejects libpq's
+// allow and prefer at connect time, which would leave the service retrying
+// forever instead of failing at startup.
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+func validPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
@@ -0,0 +1,161 @@
+// Package health implements liveness and readiness endpoints. Readiness
+// runs every registered dependency check and reports each one separately so
+// that orchestrators and the gateway can see what is failing.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Checks holds the report of an upstream service, if the dependency
+	// is one.
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context) CheckResult
+}
+
+type Checker struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+}
+
+// NewChecker returns a checker that gives each dependency check at most
+// timeout to complete.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a dependency check; a nil error means healthy.
+func (c *Checker) Add(name string, fn func(ctx context.Context) error) {
+	c.checks = append(c.checks, check{name: name, run: func(ctx context.Context) CheckResult {
+		if err := fn(ctx); err != nil {
+			return CheckResult{Status: StatusUnavailable, Error: err.Error()}
+		}
+		return CheckResult{Status: StatusOK}
+	}})
+}
+
+// AddUpstream registers the readiness endpoint of another service; its own
+// checks are included in the report.
+func (c *Checker) AddUpstream(name, url string, client *http.Client) {
+	c.checks = append(c.checks, check{name: name, run: func(ctx context.Context) CheckResult {
+		return checkUpstream(ctx, client, url)
+	}})
+}
+
+// SetDraining makes readiness fail from now on so that load balancers stop
+// sending new requests while in-flight ones finish.
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks)+1)}
+	if c.draining.Load() {
+		report.Checks["draining"] = CheckResult{Status: StatusUnavailable, Error: "server is shutting down"}
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func(ch check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			result := ch.run(checkCtx)
+			mu.Lock()
+			report.Checks[ch.name] = result
+			mu.Unlock()
+		}(ch)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+// LivenessHandler only tells that the process is up and serving; it never
+// looks at dependencies, so a database outage does not get the pod killed.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Report{Status: StatusOK})
+}
+
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, c.Run(r.Context()))
+}
+
+// StatusHandler answers like ReadinessHandler but leaves out the individual
+// checks, whose errors can reveal internal addresses and schema details.
+// It is meant for probes reachable from the public network.
+func (c *Checker) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Report{Status: c.Run(r.Context()).Status})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func checkUpstream(ctx context.Context, client *http.Client, url string) CheckResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return CheckResult{Status: StatusUnavailable, Error: err.Error()}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.New("timed out")
+		}
+		return CheckResult{Status: StatusUnavailable, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	var upstream Report
+	decodeErr := json.NewDecoder(resp.Body).Decode(&upstream)
+
+	result := CheckResult{Status: StatusOK, Checks: upstream.Checks}
+	if resp.StatusCode != http.StatusOK {
+		result.Status = StatusUnavailable
+		result.Error = fmt.Sprintf("readiness returned %d", resp.StatusCode)
+	} else if decodeErr != nil {
+		result.Status = StatusUnavailable
+		result.Error = "invalid readiness response"
+	}
+	return result
+}
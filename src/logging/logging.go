@@ -0,0 +1,126 @@
+// Package logging sets up structured JSON logging shared by the gateway and
+// the services: request IDs, per-request loggers stored in the context and
+// redaction of credentials.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeys are attribute keys and header names whose values must never
+// reach the logs. Keys containing any of sensitiveFragments are redacted too.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"code":          true,
+	"x-csrf-token":  true,
+}
+
+var sensitiveFragments = []string{"password", "token", "secret", "cookie"}
+
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, fragment := range sensitiveFragments {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns a logger writing to w. format is "json" or "text"; level is
+// one of debug, info, warn, error.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: redactAttr,
+	}
+
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+}
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() != slog.KindGroup && IsSensitive(attr.Key) {
+		return slog.String(attr.Key, redactedValue)
+	}
+	return attr
+}
+
+// Headers returns a log value for HTTP headers with credentials masked.
+func Headers(header http.Header) slog.Value {
+	attrs := make([]slog.Attr, 0, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		if IsSensitive(name) {
+			value = redactedValue
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// requestLogger is shared by all middlewares of one request, so attributes
+// added deep in the chain (such as the authenticated user) also show up in
+// the access log written by the outermost middleware.
+type requestLogger struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, &requestLogger{logger: logger})
+}
+
+// FromContext returns the request logger, or the default logger outside of
+// a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if holder, ok := ctx.Value(loggerKey).(*requestLogger); ok {
+		holder.mu.Lock()
+		defer holder.mu.Unlock()
+		return holder.logger
+	}
+	return slog.Default()
+}
+
+// AddAttrs attaches attributes to every later log line of the request.
+func AddAttrs(ctx context.Context, args ...any) {
+	if holder, ok := ctx.Value(loggerKey).(*requestLogger); ok {
+		holder.mu.Lock()
+		holder.logger = holder.logger.With(args...)
+		holder.mu.Unlock()
+	}
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
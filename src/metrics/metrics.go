@@ -0,0 +1,138 @@
+// Package metrics exposes Prometheus metrics shared by the gateway and the
+// services. Collectors live in the default registry and are served by
+// Handler.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// UpstreamErrors counts proxied requests answered by the gateway's error
+	// handler instead of the upstream service.
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_upstream_errors_total",
+		Help: "Proxied requests that failed, timed out or were rejected by the circuit breaker.",
+	}, []string{"upstream"})
+
+	UpstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_upstream_retries_total",
+		Help: "Proxied requests retried after an upstream failure.",
+	}, []string{"upstream"})
+
+	CircuitBreakerOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "proxy_circuit_breaker_open",
+		Help: "1 while the circuit breaker of an upstream is open.",
+	}, []string{"upstream"})
+
+	LegacyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_legacy_requests_total",
+		Help: "Requests to deprecated unversioned paths, by legacy route.",
+	}, []string{"route"})
+
+	// LoginAttempts is labelled with result: success, failure or
+	// two_factor_required.
+	LoginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_login_attempts_total",
+		Help: "Login attempts by result.",
+	}, []string{"result"})
+)
+
+const (
+	LoginSuccess           = "success"
+	LoginFailure           = "failure"
+	LoginTwoFactorRequired = "two_factor_required"
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records request counts and latency. It must be installed with
+// router.Use so that the matched route template is known; labelling by
+// template rather than raw path keeps cardinality bounded.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Inc()
+		httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RegisterDB exports connection pool statistics from db.Stats().
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterActiveSessions exports a gauge evaluated on every scrape.
+func RegisterActiveSessions(count func(context.Context) (int, error)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "user_active_sessions",
+		Help: "Sessions that have not expired yet.",
+	}, func() float64 {
+		n, err := count(context.Background())
+		if err != nil {
+			slog.Error("failed to count active sessions", "error", err)
+			return math.NaN()
+		}
+		return float64(n)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,227 @@
+// Package openapi checks HTTP traffic against openapi.yaml. It is meant for
+// development and tests, where it catches handlers and spec drifting apart.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	"social-network/logging"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.JSONBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("image/jpeg", openapi3filter.FileBodyDecoder)
+}
+
+// matchSlashes marks path parameters that span several segments, such as
+// media keys. Plain OpenAPI templates never match a "/".
+const matchSlashes = "x-match-slashes"
+
+// Operation identifies a documented endpoint, e.g. GET /api/v1/users/{username}.
+type Operation struct {
+	Method string
+	Path   string
+}
+
+func (o Operation) String() string {
+	return o.Method + " " + o.Path
+}
+
+type Validator struct {
+	doc     *openapi3.T
+	router  routers.Router
+	options *openapi3filter.Options
+	// paths maps router templates back to the documented ones.
+	paths map[string]string
+
+	onResponseError func(r *http.Request, err error)
+}
+
+// Load reads and validates the spec at path.
+func Load(path string) (*Validator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+
+	// The servers list describes the public gateway; requests are matched on
+	// the path alone so the same spec works behind it and in tests.
+	doc.Servers = nil
+	routing, paths := routingDoc(doc)
+	router, err := gorillamux.NewRouter(routing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI router: %w", err)
+	}
+
+	return &Validator{
+		doc:    doc,
+		router: router,
+		paths:  paths,
+		options: &openapi3filter.Options{
+			// Authentication is the handlers' job.
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			MultiError:         true,
+		},
+		onResponseError: func(r *http.Request, err error) {
+			logging.FromContext(r.Context()).Error("response does not match the API spec", "error", err)
+		},
+	}, nil
+}
+
+// routingDoc returns a copy of doc whose paths turn parameters marked with
+// x-match-slashes into gorilla/mux patterns, plus the way back to the
+// documented paths.
+func routingDoc(doc *openapi3.T) (*openapi3.T, map[string]string) {
+	routing := *doc
+	routing.Paths = openapi3.NewPaths()
+	paths := map[string]string{}
+	for path, item := range doc.Paths.Map() {
+		routed := path
+		parameters := item.Parameters
+		for _, operation := range item.Operations() {
+			parameters = append(parameters, operation.Parameters...)
+		}
+		for _, parameter := range parameters {
+			if parameter.Value == nil || parameter.Value.In != openapi3.ParameterInPath {
+				continue
+			}
+			if spans, _ := parameter.Value.Extensions[matchSlashes].(bool); spans {
+				name := parameter.Value.Name
+				routed = strings.Replace(routed, "{"+name+"}", "{"+name+":.+}", 1)
+			}
+		}
+		routing.Paths.Set(routed, item)
+		paths[routed] = path
+	}
+	return &routing, paths
+}
+
+// OnResponseError replaces the default of logging responses that don't
+// match the spec; contract tests use it to fail instead.
+func (v *Validator) OnResponseError(fn func(r *http.Request, err error)) {
+	v.onResponseError = fn
+}
+
+// Operations lists every documented operation, sorted by path and method.
+func (v *Validator) Operations() []Operation {
+	var operations []Operation
+	for path, item := range v.doc.Paths.Map() {
+		for method := range item.Operations() {
+			operations = append(operations, Operation{Method: method, Path: path})
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].Path != operations[j].Path {
+			return operations[i].Path < operations[j].Path
+		}
+		return operations[i].Method < operations[j].Method
+	})
+	return operations
+}
+
+// Match returns the documented operation a request belongs to.
+func (v *Validator) Match(r *http.Request) (Operation, bool) {
+	route, _, err := v.router.FindRoute(r)
+	if err != nil {
+		return Operation{}, false
+	}
+	return Operation{Method: route.Method, Path: v.paths[route.Path]}, true
+}
+
+// Middleware rejects requests that don't match the spec with 400 and
+// reports responses that don't match it. Undocumented paths such as
+// /healthz and /metrics are passed through untouched.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    v.options,
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			http.Error(w, "Request does not match the API spec: "+describe(err), http.StatusBadRequest)
+			return
+		}
+
+		recorder := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.status,
+			Header:                 recorder.header,
+			Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+			Options:                v.options,
+		})
+		if err != nil {
+			v.onResponseError(r, fmt.Errorf("%s %s -> %d: %s", r.Method, v.paths[route.Path], recorder.status, describe(err)))
+		}
+
+		for name, values := range recorder.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(recorder.status)
+		w.Write(recorder.body.Bytes())
+	})
+}
+
+// describe flattens the multi-errors of kin-openapi into one line.
+func describe(err error) string {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		messages := make([]string, 0, len(multi))
+		for _, e := range multi {
+			messages = append(messages, describe(e))
+		}
+		return strings.Join(messages, "; ")
+	}
+	return strings.ReplaceAll(err.Error(), "\n", " ")
+}
+
+// responseRecorder buffers the response so that it can be validated before
+// it is sent.
+type responseRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(data)
+}
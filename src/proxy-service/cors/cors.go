@@ -0,0 +1,147 @@
+// Package cors implements the gateway's cross-origin policy: an allow-list
+// of origins, credentialed requests and per-route methods and headers.
+package cors
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule lists what cross-origin callers may do under a path prefix.
+type Rule struct {
+	Prefix  string
+	Methods []string
+	Headers []string
+}
+
+type Options struct {
+	// AllowedOrigins are exact origins such as "https://app.example.com";
+	// "*" allows any origin and cannot be combined with AllowCredentials.
+	AllowedOrigins   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+	// ExposedHeaders are response headers readable by scripts.
+	ExposedHeaders []string
+	Rules          []Rule
+}
+
+type Policy struct {
+	origins   map[string]bool
+	anyOrigin bool
+	opts      Options
+}
+
+func New(opts Options) *Policy {
+	policy := &Policy{origins: map[string]bool{}, opts: opts}
+	for _, origin := range opts.AllowedOrigins {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		policy.origins[NormalizeOrigin(origin)] = true
+	}
+	return policy
+}
+
+// NormalizeOrigin lowercases the scheme and host and drops a trailing
+// slash, the form browsers send in the Origin header.
+func NormalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(origin, "/"))
+}
+
+func (p *Policy) originAllowed(origin string) bool {
+	return p.anyOrigin || p.origins[NormalizeOrigin(origin)]
+}
+
+// rule returns the rule with the longest matching prefix.
+func (p *Policy) rule(path string) (Rule, bool) {
+	var best Rule
+	found := false
+	for _, rule := range p.opts.Rules {
+		if strings.HasPrefix(path, rule.Prefix) && (!found || len(rule.Prefix) > len(best.Prefix)) {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
+
+// sameOrigin reports whether the Origin header names the gateway itself,
+// which browsers also send on same-origin POST requests.
+func sameOrigin(r *http.Request, origin string) bool {
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, r.Host)
+}
+
+// Middleware answers preflight requests and adds CORS headers to actual
+// ones. Requests from origins that are not allowed are rejected with 403;
+// requests without an Origin header are not cross-origin and pass through.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || sameOrigin(r, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		rule, ok := p.rule(r.URL.Path)
+		if !ok || !p.originAllowed(origin) {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		if p.anyOrigin && !p.opts.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.opts.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || requestedMethod == "" {
+			if len(p.opts.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.opts.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.preflight(w, r, rule, requestedMethod)
+	})
+}
+
+func (p *Policy) preflight(w http.ResponseWriter, r *http.Request, rule Rule, requestedMethod string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if !slices.Contains(rule.Methods, strings.ToUpper(requestedMethod)) {
+		http.Error(w, "Method not allowed by CORS policy", http.StatusForbidden)
+		return
+	}
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(rule.Headers, func(allowed string) bool { return strings.EqualFold(allowed, header) }) {
+			http.Error(w, "Header not allowed by CORS policy", http.StatusForbidden)
+			return
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(rule.Methods, ", "))
+	if len(rule.Headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(rule.Headers, ", "))
+	}
+	if p.opts.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.opts.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,120 +1,285 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+
+	"social-network/config"
+	"social-network/health"
+	"social-network/logging"
+	"social-network/metrics"
+	"social-network/proxy-service/cors"
+	"social-network/proxy-service/secure"
+	"social-network/proxy-service/upstream"
+	"social-network/proxy-service/versioning"
+	"social-network/tracing"
+)
+
+const (
+	healthCheckTimeout = 2 * time.Second
+	readHeaderTimeout  = 10 * time.Second
+	idleTimeout        = 120 * time.Second
 )
 
+// csrfHeader is checked by the user service; the gateway only has to let
+// browsers send it cross-origin.
+const csrfHeader = "X-CSRF-Token"
+
+const apiPrefix = "/api/v1"
+
+// legacyDeprecatedAt is when the unversioned paths were superseded by
+// apiPrefix.
+var legacyDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// legacyRoutes keeps clients of the unversioned API working. /users/profile
+// and /users/update used to shadow the profiles of users with those names.
+func legacyRoutes(cfg *config.Proxy) *versioning.Mapper {
+	return versioning.New(versioning.Options{
+		DeprecatedAt: legacyDeprecatedAt,
+		Sunset:       cfg.LegacySunsetTime(),
+		Routes: []versioning.Route{
+			{Legacy: "/auth/", Current: apiPrefix + "/auth/"},
+			{Legacy: "/users/profile", Current: apiPrefix + "/users/me"},
+			{Legacy: "/users/update", Current: apiPrefix + "/users/me"},
+			{Legacy: "/users", Current: apiPrefix + "/users"},
+			{Legacy: "/users/", Current: apiPrefix + "/users/"},
+			{Legacy: "/admin/", Current: apiPrefix + "/admin/"},
+		},
+	})
+}
+
 func main() {
-	port := getEnv("PORT", "8080")
-	userServiceURL := getEnv("USER_SERVICE_URL", "http://user-service:8000")
+	cfg, err := config.LoadProxy(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	if effective, err := json.Marshal(config.Redact(cfg)); err == nil {
+		slog.Info("effective configuration", "config", json.RawMessage(effective))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "proxy-service", cfg.Tracing.Options())
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
 
 	router := mux.NewRouter()
 
-	targetURL, err := url.Parse(userServiceURL)
+	targetURL, err := url.Parse(cfg.UserServiceURL)
 	if err != nil {
-		log.Fatalf("Error parsing user service URL: %v", err)
+		fatal("invalid user service URL", err)
 	}
 
 	userServiceProxy := httputil.NewSingleHostReverseProxy(targetURL)
+	userServiceProxy.Transport = upstream.NewTransport("user-service", upstreamOptions(cfg.Upstream), tracing.Transport)
 
 	originalDirector := userServiceProxy.Director
 	userServiceProxy.Director = func(req *http.Request) {
 		originalDirector(req)
 		req.Header.Set("X-Proxy", "Social Network Proxy")
-		if cookies := req.Cookies(); len(cookies) > 0 {
-			log.Printf("Proxying cookies: %v", cookies)
+		if req.TLS != nil {
+			req.Header.Set("X-Forwarded-Proto", "https")
+		} else {
+			req.Header.Set("X-Forwarded-Proto", "http")
 		}
+		req.Header.Set(logging.RequestIDHeader, logging.RequestIDFromContext(req.Context()))
+		logging.FromContext(req.Context()).Debug("proxying request", "headers", logging.Headers(req.Header))
 	}
 	userServiceProxy.ModifyResponse = func(resp *http.Response) error {
-		cookies := resp.Cookies()
-		if len(cookies) > 0 {
-			log.Printf("Response setting cookies: %v", cookies)
+		// The gateway already set the request ID on the response; drop the
+		// upstream copy so clients don't see it twice.
+		resp.Header.Del(logging.RequestIDHeader)
+		// resp.Request is a clone of the client request and keeps its TLS
+		// state.
+		if resp.Request.TLS != nil {
+			secure.Cookies(resp.Header)
 		}
+		logging.FromContext(resp.Request.Context()).Debug("upstream response",
+			"status", resp.StatusCode,
+			"headers", logging.Headers(resp.Header),
+		)
 		return nil
 	}
+	userServiceProxy.ErrorHandler = upstream.ErrorHandler("user-service")
 
-	router.PathPrefix("/auth/").Handler(userServiceProxy)
-	router.PathPrefix("/users/").Handler(userServiceProxy)
+	router.PathPrefix(apiPrefix + "/").Handler(userServiceProxy)
+	router.PathPrefix("/media/").Handler(userServiceProxy)
 
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	// The gateway is only as ready as the services behind it; /health is
+	// kept as an alias of /readyz for existing probes. The public port only
+	// tells whether it is ready, the report with upstream errors is served
+	// on the internal port.
+	checker := health.NewChecker(healthCheckTimeout)
+	checker.AddUpstream("user-service", strings.TrimSuffix(cfg.UserServiceURL, "/")+"/readyz", &http.Client{})
+	router.HandleFunc("/healthz", checker.LivenessHandler).Methods("GET")
+	router.HandleFunc("/readyz", checker.StatusHandler).Methods("GET")
+	router.HandleFunc("/health", checker.StatusHandler).Methods("GET")
 
-	router.Use(loggingMiddleware)
-	router.Use(corsMiddleware)
+	router.Use(logging.Middleware(logger))
+	router.Use(tracing.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(newCORSPolicy(cfg.CORS).Middleware)
 
-	addr := fmt.Sprintf(":%s", port)
-	log.Printf("Proxy service starting on %s...", addr)
-	log.Printf("Proxying requests to user service at %s", userServiceURL)
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           tracing.Handler(secure.HSTS(cfg.TLS.HSTSMaxAge, cfg.TLS.HSTSIncludeSubdomains)(legacyRoutes(cfg).Middleware(router)), "proxy-service"),
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
 
-	if err := http.ListenAndServe(addr, router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Metrics and detailed health reports are only served on the internal
+	// port, which is not published.
+	internalRouter := mux.NewRouter()
+	internalRouter.Handle("/metrics", metrics.Handler()).Methods("GET")
+	internalRouter.HandleFunc("/healthz", checker.LivenessHandler).Methods("GET")
+	internalRouter.HandleFunc("/readyz", checker.ReadinessHandler).Methods("GET")
+	internalServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.InternalPort),
+		Handler:           internalRouter,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
 	}
-}
 
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok","message":"proxy service is running"}`))
-}
+	stopBackground := make(chan struct{})
+	var certificates *secure.CertReloader
+	if cfg.TLS.Enabled() {
+		certificates, err = secure.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			fatal("failed to load TLS certificate", err)
+		}
+		// Setting TLSConfig makes ListenAndServeTLS negotiate HTTP/2 as well.
+		server.TLSConfig = certificates.TLSConfig()
+		if cfg.TLS.ReloadInterval > 0 {
+			go certificates.Watch(cfg.TLS.ReloadInterval, stopBackground)
+		}
+	}
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		log.Printf("Request: %s %s", r.Method, r.URL.Path)
-		wrapper := newResponseWriterWrapper(w)
-		next.ServeHTTP(wrapper, r)
-		log.Printf("Response: %d %s %s [%v]",
-			wrapper.statusCode,
-			r.Method,
-			r.URL.Path,
-			time.Since(start),
+	go func() {
+		slog.Info("proxy service starting",
+			"addr", server.Addr,
+			"tls", cfg.TLS.Enabled(),
+			"user_service_url", cfg.UserServiceURL,
 		)
-	})
+		var err error
+		if certificates != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("failed to start server", err)
+		}
+	}()
+
+	go func() {
+		slog.Info("internal listener starting", "addr", internalServer.Addr)
+		if err := internalServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("failed to start internal listener", err)
+		}
+	}()
+
+	shutdownGracefully(server, internalServer, checker, certificates, cfg, stopBackground, shutdownTracing)
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// shutdownGracefully reloads the certificate on SIGHUP and drains the
+// server on SIGINT or SIGTERM, the same way the user service does.
+func shutdownGracefully(
+	server *http.Server,
+	internalServer *http.Server,
+	checker *health.Checker,
+	certificates *secure.CertReloader,
+	cfg *config.Proxy,
+	stopBackground chan<- struct{},
+	shutdownTracing func(context.Context) error,
+) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if certificates == nil {
+			continue
+		}
+		if err := certificates.Reload(); err != nil {
+			slog.Error("failed to reload TLS certificate", "error", err)
+			continue
 		}
+		slog.Info("reloaded TLS certificate", "cert_file", cfg.TLS.CertFile)
+	}
 
-		next.ServeHTTP(w, r)
-	})
-}
+	checker.SetDraining()
+	slog.Info("draining", "delay", cfg.DrainDelay)
+	time.Sleep(cfg.DrainDelay)
 
-type responseWriterWrapper struct {
-	http.ResponseWriter
-	statusCode int
+	slog.Info("shutting down gracefully")
+	close(stopBackground)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("server shutdown error", "error", err)
+	}
+	if err := internalServer.Shutdown(ctx); err != nil {
+		slog.Error("internal listener shutdown error", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+
+	slog.Info("server stopped gracefully")
 }
 
-func newResponseWriterWrapper(w http.ResponseWriter) *responseWriterWrapper {
-	return &responseWriterWrapper{
-		ResponseWriter: w,
-		statusCode:     http.StatusOK,
+func upstreamOptions(cfg config.UpstreamConfig) upstream.Options {
+	return upstream.Options{
+		DialTimeout:           cfg.DialTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		MaxRetries:            cfg.MaxRetries,
+		RetryBackoff:          cfg.RetryBackoff,
+		BreakerFailures:       cfg.BreakerFailures,
+		BreakerOpenTimeout:    cfg.BreakerOpenTimeout,
 	}
 }
 
-func (rww *responseWriterWrapper) WriteHeader(statusCode int) {
-	rww.statusCode = statusCode
-	rww.ResponseWriter.WriteHeader(statusCode)
+// newCORSPolicy mirrors the routes of the user service: browsers may only
+// preflight the methods and headers a route actually accepts.
+func newCORSPolicy(cfg config.CORSConfig) *cors.Policy {
+	return cors.New(cors.Options{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+		ExposedHeaders:   []string{"ETag", logging.RequestIDHeader, "Retry-After", "Deprecation", "Sunset", "Link"},
+		Rules: []cors.Rule{
+			{Prefix: apiPrefix + "/auth/", Methods: []string{"POST"}, Headers: []string{"Content-Type", "Authorization", csrfHeader, logging.RequestIDHeader}},
+			{Prefix: apiPrefix + "/users", Methods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"}, Headers: []string{"Content-Type", "Authorization", "If-Match", csrfHeader, logging.RequestIDHeader}},
+			{Prefix: apiPrefix + "/admin/", Methods: []string{"GET", "POST", "PUT"}, Headers: []string{"Content-Type", "Authorization", csrfHeader, logging.RequestIDHeader}},
+			{Prefix: "/media/", Methods: []string{"GET"}, Headers: []string{logging.RequestIDHeader}},
+		},
+	})
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,146 @@
+// Package secure holds what the gateway needs to serve HTTPS: certificates
+// that are reloaded without a restart, HSTS and Secure cookies.
+package secure
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate loaded from files and picks up renewed
+// files, e.g. written by cert-manager or certbot, on Reload or Watch.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Reload loads the key pair again. On failure the previous certificate
+// stays in use.
+func (c *CertReloader) Reload() error {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Watch reloads the certificate whenever one of the files changes, checking
+// every interval until stop is closed.
+func (c *CertReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			modTime, err := c.latestModTime()
+			c.mu.RLock()
+			changed := err == nil && modTime.After(c.modTime)
+			c.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := c.Reload(); err != nil {
+				slog.Error("failed to reload TLS certificate", "error", err)
+				continue
+			}
+			slog.Info("reloaded TLS certificate", "cert_file", c.certFile)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// TLSConfig offers HTTP/2 and HTTP/1.1 with TLS 1.2 or newer.
+func (c *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: c.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// HSTS tells browsers to use HTTPS only. The header is sent on TLS
+// connections only, as browsers ignore it over plain HTTP anyway.
+func HSTS(maxAge time.Duration, includeSubdomains bool) func(http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	if includeSubdomains {
+		value += "; includeSubDomains"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && maxAge > 0 {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Cookies adds the Secure flag to every Set-Cookie header. The services
+// behind the gateway speak plain HTTP and cannot know that the client
+// connection is encrypted.
+func Cookies(header http.Header) {
+	values := header.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+
+	header.Del("Set-Cookie")
+	for _, value := range values {
+		cookie, err := http.ParseSetCookie(value)
+		if err != nil {
+			header.Add("Set-Cookie", value)
+			continue
+		}
+		cookie.Secure = true
+		header.Add("Set-Cookie", cookie.String())
+	}
+}
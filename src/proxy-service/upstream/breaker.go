@@ -0,0 +1,123 @@
+package upstream
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is what Allow returns while the circuit is open. It
+// matches ErrCircuitOpen with errors.Is.
+type CircuitOpenError struct {
+	// RetryAfter is how long until the breaker lets the next probe through.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string { return ErrCircuitOpen.Error() }
+
+func (e *CircuitOpenError) Unwrap() error { return ErrCircuitOpen }
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// Breaker stops sending requests to an upstream after a run of consecutive
+// failures. Once openTimeout has passed it lets a single probe through and
+// closes again if that succeeds.
+type Breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	onChanged func(open bool)
+}
+
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, openTimeout: openTimeout, now: time.Now}
+}
+
+// OnStateChange registers a callback used to export the breaker state.
+func (b *Breaker) OnStateChange(fn func(open bool)) {
+	b.onChanged = fn
+}
+
+// Allow returns a *CircuitOpenError if the request must not be sent.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if elapsed := b.now().Sub(b.openedAt); elapsed < b.openTimeout {
+			return &CircuitOpenError{RetryAfter: b.openTimeout - elapsed}
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return nil
+	case stateHalfOpen:
+		if b.probing {
+			// The probe decides within moments whether the circuit closes.
+			return &CircuitOpenError{}
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		wasOpen := b.state != stateClosed
+		b.state = stateClosed
+		b.failures = 0
+		b.probing = false
+		if wasOpen {
+			b.notify(false)
+		}
+		return
+	}
+
+	if b.state == stateOpen {
+		// A request sent before the circuit opened; keep the timer.
+		return
+	}
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		wasClosed := b.state == stateClosed
+		b.state = stateOpen
+		b.openedAt = b.now()
+		b.probing = false
+		if wasClosed {
+			b.notify(true)
+		}
+	}
+}
+
+// Abandon releases a probe whose outcome is unknown, e.g. because the
+// client went away, without counting it either way.
+func (b *Breaker) Abandon() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *Breaker) notify(open bool) {
+	if b.onChanged != nil {
+		b.onChanged(open)
+	}
+}
@@ -0,0 +1,76 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"social-network/logging"
+	"social-network/metrics"
+)
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	Upstream  string `json:"upstream"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorHandler answers with 503 while the circuit is open, 504 when the
+// upstream timed out and 502 for any other failure. The 503 carries a
+// Retry-After for when the breaker lets requests through again.
+func ErrorHandler(name string) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		status := http.StatusBadGateway
+		message := "Upstream service unavailable"
+		switch {
+		case errors.Is(err, ErrCircuitOpen):
+			status = http.StatusServiceUnavailable
+			message = "Upstream service temporarily unavailable"
+			w.Header().Set("Retry-After", retryAfterSeconds(err))
+		case isTimeout(err):
+			status = http.StatusGatewayTimeout
+			message = "Upstream service timed out"
+		case errors.Is(err, context.Canceled):
+			// The client went away; nobody will read the response.
+			logging.FromContext(r.Context()).Debug("client canceled request", "upstream", name)
+			return
+		}
+
+		logging.FromContext(r.Context()).Error("upstream request failed",
+			"upstream", name,
+			"status", status,
+			"error", err,
+		)
+		metrics.UpstreamErrors.WithLabelValues(name).Inc()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(errorResponse{
+			Error:     message,
+			Upstream:  name,
+			RequestID: logging.RequestIDFromContext(r.Context()),
+		})
+	}
+}
+
+func retryAfterSeconds(err error) string {
+	retryAfter := time.Second
+	var openErr *CircuitOpenError
+	if errors.As(err, &openErr) {
+		retryAfter = max(openErr.RetryAfter, time.Second)
+	}
+	return strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,133 @@
+// Package upstream makes the gateway's reverse proxy resilient to a slow or
+// failing service: transport timeouts, retries of idempotent requests, a
+// circuit breaker and JSON error responses.
+package upstream
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"social-network/logging"
+	"social-network/metrics"
+)
+
+type Options struct {
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	// MaxRetries is the number of extra attempts for GET and HEAD requests.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// BreakerFailures consecutive failures open the circuit for
+	// BreakerOpenTimeout.
+	BreakerFailures    int
+	BreakerOpenTimeout time.Duration
+}
+
+// Transport sends requests to one upstream service.
+type Transport struct {
+	name    string
+	base    http.RoundTripper
+	breaker *Breaker
+	opts    Options
+}
+
+// NewTransport wraps an http.Transport configured with the timeouts from
+// opts; wrap is applied on top of it, e.g. to inject trace headers.
+func NewTransport(name string, opts Options, wrap func(http.RoundTripper) http.RoundTripper) *Transport {
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		TLSHandshakeTimeout:   opts.DialTimeout,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	breaker := NewBreaker(opts.BreakerFailures, opts.BreakerOpenTimeout)
+	breaker.OnStateChange(func(open bool) {
+		value := 0.0
+		if open {
+			value = 1
+		}
+		metrics.CircuitBreakerOpen.WithLabelValues(name).Set(value)
+	})
+
+	var roundTripper http.RoundTripper = base
+	if wrap != nil {
+		roundTripper = wrap(base)
+	}
+	return &Transport{name: name, base: roundTripper, breaker: breaker, opts: opts}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if retryable(req) {
+		attempts += t.opts.MaxRetries
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := t.breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+			t.breaker.Abandon()
+			return nil, err
+		}
+
+		failed := err != nil || upstreamUnavailable(resp.StatusCode)
+		t.breaker.Record(!failed)
+		if !failed || attempt >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		delay := t.backoff(attempt)
+		logging.FromContext(req.Context()).Warn("retrying upstream request",
+			"upstream", t.name,
+			"attempt", attempt,
+			"retry_in", delay,
+		)
+		metrics.UpstreamRetries.WithLabelValues(t.name).Inc()
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// backoff doubles the base delay with every attempt and applies full
+// jitter, so that retries from many clients do not arrive in lockstep.
+func (t *Transport) backoff(attempt int) time.Duration {
+	ceiling := t.opts.RetryBackoff << (attempt - 1)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// retryable only admits requests that are safe to repeat and whose body,
+// if any, has not been consumed.
+func retryable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+func upstreamUnavailable(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
@@ -0,0 +1,90 @@
+// Package versioning maps the gateway's legacy unversioned paths onto the
+// current API version and marks them as deprecated.
+package versioning
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"social-network/metrics"
+)
+
+// Route maps a legacy path to its replacement. A Legacy path ending in "/"
+// is a prefix and the rest of the path is kept.
+type Route struct {
+	Legacy  string
+	Current string
+}
+
+type Options struct {
+	Routes []Route
+	// DeprecatedAt is sent in the Deprecation header (RFC 9745).
+	DeprecatedAt time.Time
+	// Sunset, if set, is when the legacy paths stop working (RFC 8594).
+	Sunset time.Time
+}
+
+type Mapper struct {
+	opts Options
+}
+
+func New(opts Options) *Mapper {
+	return &Mapper{opts: opts}
+}
+
+// match returns the route for a legacy path. Exact routes win over prefixes
+// and longer prefixes over shorter ones.
+func (m *Mapper) match(path string) (Route, bool) {
+	var best Route
+	found := false
+	for _, route := range m.opts.Routes {
+		if route.Legacy == path {
+			return route, true
+		}
+		if strings.HasSuffix(route.Legacy, "/") && strings.HasPrefix(path, route.Legacy) &&
+			(!found || len(route.Legacy) > len(best.Legacy)) {
+			best, found = route, true
+		}
+	}
+	return best, found
+}
+
+// Rewrite returns the current path for a legacy one.
+func (m *Mapper) Rewrite(path string) (string, bool) {
+	route, ok := m.match(path)
+	if !ok {
+		return "", false
+	}
+	if route.Legacy == path {
+		return route.Current, true
+	}
+	return route.Current + strings.TrimPrefix(path, route.Legacy), true
+}
+
+// Middleware must wrap the router so that routing only ever sees current
+// paths. Responses to legacy paths carry Deprecation, Sunset and a Link to
+// the successor.
+func (m *Mapper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := m.match(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		current, _ := m.Rewrite(r.URL.Path)
+
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(m.opts.DeprecatedAt.Unix(), 10))
+		if !m.opts.Sunset.IsZero() {
+			w.Header().Set("Sunset", m.opts.Sunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Add("Link", "<"+current+`>; rel="successor-version"`)
+		// The pattern rather than the path keeps the label set bounded.
+		metrics.LegacyRequests.WithLabelValues(route.Legacy).Inc()
+
+		r.URL.Path = current
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,480 @@
+// Package commands implements the snctl commands. They work directly on the
+// user-service database, reusing its repository and service packages.
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"social-network/config"
+	"social-network/user-service/api"
+	"social-network/user-service/models"
+	"social-network/user-service/password"
+	"social-network/user-service/repository"
+	"social-network/user-service/service"
+	"social-network/user-service/setup"
+	"social-network/user-service/sms"
+)
+
+// auditActor is recorded in the audit log for changes made with snctl.
+const auditActor = "snctl"
+
+type Command struct {
+	Name    string
+	Summary string
+	run     func(ctx context.Context, a *App, args []string) error
+}
+
+// Run parses the command's flags and runs it. Flag errors, including
+// flag.ErrHelp for -h, are returned before the database is touched.
+func (c Command) Run(ctx context.Context, a *App, args []string) error {
+	return c.run(ctx, a, args)
+}
+
+var Commands = []Command{
+	{"migrate", "create or update the database schema", runMigrate},
+	{"create-user", "create an account, optionally with a role", runCreateUser},
+	{"reset-password", "set a new password and log the user out everywhere", runResetPassword},
+	{"sessions", "list or revoke a user's sessions", runSessions},
+	{"seed", "create test accounts", runSeed},
+	{"dump", "print all data stored about a user as JSON", runDump},
+}
+
+// App holds what the commands share.
+type App struct {
+	cfg    *config.UserService
+	out    io.Writer
+	policy password.Policy
+	hasher password.Hasher
+	sms    sms.Sender
+
+	db            *sql.DB
+	userRepo      *repository.UserRepository
+	sessionRepo   *repository.SessionRepository
+	twoFactorRepo *repository.TwoFactorRepository
+	auditRepo     *repository.AuditRepository
+	phoneRepo     *repository.PhoneVerificationRepository
+	userService   *service.UserService
+}
+
+// Lookup returns the command called name.
+func Lookup(name string) (Command, bool) {
+	for _, c := range Commands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Command{}, false
+}
+
+// New prepares the commands for cfg; the database is only opened by the
+// commands that need it. Output goes to out.
+func New(cfg *config.UserService, out io.Writer) (*App, error) {
+	hasher, err := setup.PasswordHasher(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := setup.PasswordPolicy(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	api.SetPasswordPolicy(policy)
+	sender, err := setup.SMSSender(cfg.Phone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMS configuration: %w", err)
+	}
+
+	return &App{cfg: cfg, out: out, policy: policy, hasher: hasher, sms: sender}, nil
+}
+
+// connect opens the database unless UseDB provided one. Commands call it
+// once their flags are parsed, so that usage errors and -h don't need a
+// reachable database.
+func (a *App) connect() error {
+	if a.db != nil {
+		return nil
+	}
+	db, err := sql.Open("postgres", a.cfg.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.Database.ConnectTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	a.UseDB(db)
+	return nil
+}
+
+// UseDB makes the commands work on db, which Close then closes.
+func (a *App) UseDB(db *sql.DB) {
+	a.db = db
+	a.userRepo = repository.NewUserRepository(db)
+	a.sessionRepo = repository.NewSessionRepository(db)
+	a.twoFactorRepo = repository.NewTwoFactorRepository(db)
+	a.auditRepo = repository.NewAuditRepository(db)
+	a.phoneRepo = repository.NewPhoneVerificationRepository(db)
+	phoneService := service.NewPhoneService(a.userRepo, a.phoneRepo, a.sms, service.PhoneOptions{
+		DefaultRegion: a.cfg.Phone.DefaultRegion,
+		RequireUnique: a.cfg.Phone.RequireUnique,
+	})
+	a.userService = service.NewUserService(a.userRepo, a.sessionRepo, a.twoFactorRepo, a.auditRepo, phoneService, a.hasher, a.cfg.Session.TTL)
+}
+
+func (a *App) Close() {
+	if a.db != nil {
+		a.db.Close()
+	}
+}
+
+// newFlagSet returns a flag set that reports errors instead of exiting, so
+// that usage errors and -h are handled in one place.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("snctl "+name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	return flags
+}
+
+func required(flags *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if flags.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("-%s is required", name)
+		}
+	}
+	return nil
+}
+
+func runMigrate(ctx context.Context, a *App, args []string) error {
+	if err := newFlagSet("migrate").Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	// Same order as at user-service startup: the other tables reference users.
+	initializers := []struct {
+		name string
+		init func() error
+	}{
+		{"user", a.userRepo.Init},
+		{"session", a.sessionRepo.Init},
+		{"two-factor", a.twoFactorRepo.Init},
+		{"audit", a.auditRepo.Init},
+		{"phone verification", a.phoneRepo.Init},
+	}
+	for _, initializer := range initializers {
+		if err := initializer.init(); err != nil {
+			return fmt.Errorf("failed to initialize %s repository: %w", initializer.name, err)
+		}
+		fmt.Fprintf(a.out, "%s schema up to date\n", initializer.name)
+	}
+	return nil
+}
+
+func runCreateUser(ctx context.Context, a *App, args []string) error {
+	flags := newFlagSet("create-user")
+	username := flags.String("username", "", "username of the new account")
+	email := flags.String("email", "", "email address")
+	plain := flags.String("password", "", "password; a random one is generated and printed if empty")
+	role := flags.String("role", string(models.RoleUser), "user, moderator or admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := required(flags, "username", "email"); err != nil {
+		return err
+	}
+	parsedRole, err := models.ParseRole(*role)
+	if err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	generated := *plain == ""
+	if generated {
+		if *plain, err = a.GeneratePassword(*username, *email); err != nil {
+			return err
+		}
+	}
+
+	req := &models.SignInRequest{Username: *username, Email: *email, Password: *plain}
+	if err := api.Validate(req); err != nil {
+		return err
+	}
+	if _, err := a.userService.SignIn(ctx, req); err != nil {
+		return err
+	}
+	if parsedRole != models.RoleUser {
+		user, err := a.userRepo.GetUserByUsername(ctx, *username)
+		if err == nil {
+			err = repository.InTx(ctx, a.db, func(tx *sql.Tx) error {
+				if err := a.userRepo.WithTx(tx).SetRole(ctx, user.Username, parsedRole); err != nil {
+					return err
+				}
+				return a.auditRepo.WithTx(tx).Record(ctx, auditActor, user, service.AuditActionRoleChange, string(parsedRole))
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("user created, but setting the role failed: %w", err)
+		}
+	}
+
+	fmt.Fprintf(a.out, "created %s (%s)\n", *username, parsedRole)
+	if generated {
+		fmt.Fprintf(a.out, "password: %s\n", *plain)
+	}
+	return nil
+}
+
+func runResetPassword(ctx context.Context, a *App, args []string) error {
+	flags := newFlagSet("reset-password")
+	username := flags.String("username", "", "account to reset")
+	plain := flags.String("password", "", "new password; if empty a temporary one is generated and must be changed at the next login")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := required(flags, "username"); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	user, err := a.userRepo.GetUserByUsername(ctx, *username)
+	if err != nil {
+		return err
+	}
+
+	temporary := *plain == ""
+	if temporary {
+		if *plain, err = a.GeneratePassword(user.Username, user.Email); err != nil {
+			return err
+		}
+	} else if violations := a.policy.Check(*plain, user.Username, user.Email); len(violations) > 0 {
+		messages := make([]string, len(violations))
+		for i, violation := range violations {
+			messages[i] = "password " + violation.Message
+		}
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	hash, err := a.hasher.Hash(*plain)
+	if err != nil {
+		return err
+	}
+	err = repository.InTx(ctx, a.db, func(tx *sql.Tx) error {
+		users := a.userRepo.WithTx(tx)
+		if err := users.UpdatePassword(ctx, user.Username, hash); err != nil {
+			return err
+		}
+		if temporary {
+			if err := users.SetPasswordResetRequired(ctx, user.Username, true); err != nil {
+				return err
+			}
+		}
+		if err := a.sessionRepo.WithTx(tx).DeleteAllUserSessions(ctx, user.Username); err != nil {
+			return err
+		}
+		return a.auditRepo.WithTx(tx).Record(ctx, auditActor, user, service.AuditActionPasswordReset, "password set by an operator")
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "password of %s reset, all sessions revoked\n", user.Username)
+	if temporary {
+		fmt.Fprintf(a.out, "temporary password: %s\n", *plain)
+	}
+	return nil
+}
+
+func runSessions(ctx context.Context, a *App, args []string) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "revoke") {
+		return errors.New("usage: snctl sessions list|revoke -username NAME [-id ID | -all]")
+	}
+	action := args[0]
+
+	flags := newFlagSet("sessions " + action)
+	username := flags.String("username", "", "owner of the sessions")
+	id := flags.String("id", "", "session to revoke, as shown by \"sessions list\"")
+	all := flags.Bool("all", false, "revoke every session of the user")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := required(flags, "username"); err != nil {
+		return err
+	}
+	if *all == (*id != "") && action == "revoke" {
+		return errors.New("exactly one of -id and -all is required")
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	if action == "revoke" {
+		if *all {
+			if err := a.sessionRepo.DeleteAllUserSessions(ctx, *username); err != nil {
+				return err
+			}
+			fmt.Fprintf(a.out, "revoked all sessions of %s\n", *username)
+			return nil
+		}
+		if err := a.sessionRepo.DeleteUserSession(ctx, *username, *id); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "revoked session %s of %s\n", *id, *username)
+		return nil
+	}
+
+	user, err := a.userRepo.GetUserByUsername(ctx, *username)
+	if err != nil {
+		return err
+	}
+	sessions, err := a.sessionRepo.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tCREATED\tEXPIRES\tSTATUS")
+	for _, session := range sessions {
+		status := "active"
+		if session.ExpiresAt.Before(time.Now()) {
+			status = "expired"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n",
+			session.ID,
+			session.CreatedAt.Format(time.RFC3339),
+			session.ExpiresAt.Format(time.RFC3339),
+			status,
+		)
+	}
+	return table.Flush()
+}
+
+var (
+	seedNames    = []string{"Anna", "Boris", "Vera", "Gleb", "Daria", "Egor", "Zoya", "Ivan", "Katya", "Lev"}
+	seedSurnames = []string{"Ivanova", "Petrov", "Sidorova", "Smirnov", "Kuznetsova", "Popov", "Volkova", "Sokolov"}
+)
+
+// runSeed creates numbered accounts with made-up profiles. Existing
+// accounts are skipped, so it can be run again to top up.
+func runSeed(ctx context.Context, a *App, args []string) error {
+	flags := newFlagSet("seed")
+	count := flags.Int("count", 10, "number of accounts")
+	prefix := flags.String("prefix", "seed_user_", "username prefix; a number is appended")
+	plain := flags.String("password", "Seed-passw0rd", "password of every seeded account")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *count < 1 || *count > 10000 {
+		return errors.New("-count must be between 1 and 10000")
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	// Hashing once keeps seeding fast with production bcrypt costs.
+	hash, err := a.hasher.Hash(*plain)
+	if err != nil {
+		return err
+	}
+
+	created := 0
+	for i := 1; i <= *count; i++ {
+		username := fmt.Sprintf("%s%03d", *prefix, i)
+		exists, err := a.userRepo.UserExists(ctx, username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		name := seedNames[i%len(seedNames)]
+		user := &models.User{
+			Username:  username,
+			Password:  hash,
+			Email:     username + "@example.com",
+			Name:      name,
+			Surname:   seedSurnames[i%len(seedSurnames)],
+			Birthdate: models.NewNullDate(models.NewDate(1970+i%35, time.Month(i%12+1), i%28+1)),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := a.userRepo.CreateUser(ctx, user); err != nil {
+			return fmt.Errorf("failed to create %s: %w", username, err)
+		}
+		created++
+	}
+
+	fmt.Fprintf(a.out, "created %d accounts, %d already existed; password: %s\n", created, *count-created, *plain)
+	return nil
+}
+
+func runDump(ctx context.Context, a *App, args []string) error {
+	flags := newFlagSet("dump")
+	username := flags.String("username", "", "account to dump")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := required(flags, "username"); err != nil {
+		return err
+	}
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	export, err := a.userService.ExportUserData(ctx, *username)
+	if err != nil {
+		return err
+	}
+
+	out := a.out
+	if *output != "" {
+		// The dump contains personal data; keep it private to the operator.
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// GeneratePassword returns a random password that satisfies the policy.
+func (a *App) GeneratePassword(personalInfo ...string) (string, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		raw := make([]byte, 12)
+		if _, err := rand.Read(raw); err != nil {
+			return "", err
+		}
+		candidate := base64.RawURLEncoding.EncodeToString(raw)
+		if len(a.policy.Check(candidate, personalInfo...)) == 0 {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("failed to generate a password that satisfies the policy")
+}
@@ -0,0 +1,75 @@
+// snctl is the operators' command-line tool. It works directly on the
+// user-service database, reusing the user-service configuration and its
+// repository and service packages.
+//
+//	snctl [config flags] <command> [command flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"social-network/config"
+	"social-network/snctl/commands"
+	"social-network/user-service/setup"
+)
+
+func main() {
+	cfg, args, err := config.LoadUserServiceCommand("snctl", os.Args[1:])
+	if err == nil {
+		err = setup.Check(cfg)
+	}
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			usage()
+			return
+		}
+		fatal(fmt.Errorf("invalid configuration: %w", err))
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	selected, ok := commands.Lookup(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "snctl: unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	app, err := commands.New(cfg, os.Stdout)
+	if err != nil {
+		fatal(err)
+	}
+	defer app.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := selected.Run(ctx, app, args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: snctl [config flags] <command> [command flags]")
+	fmt.Fprintln(os.Stderr, "\nThe database is configured like user-service: -config, environment variables or flags.")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands.Commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", c.Name, c.Summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun \"snctl <command> -h\" for the flags of a command.")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "snctl:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,85 @@
+package integration
+
+import (
+	"context"
+	"errors"
+
+	"social-network/client"
+	"social-network/user-service/models"
+)
+
+const contractClientUser = "test_contract_client"
+
+// TestClient runs the Go client against the real handlers of the contract
+// suite's in-process user-service.
+func (s *ContractTestSuite) TestClient() {
+	ctx := context.Background()
+	c, err := client.New(s.server.URL, client.Options{MaxRetries: 1})
+	s.Require().NoError(err)
+
+	_, err = c.SignUp(ctx, &models.SignInRequest{
+		Username: contractClientUser,
+		Email:    contractClientUser + "@example.com",
+		Password: contractPassword,
+	})
+	s.Require().NoError(err)
+	_, err = c.SignUp(ctx, &models.SignInRequest{
+		Username: contractClientUser,
+		Email:    contractClientUser + "@example.com",
+		Password: contractPassword,
+	})
+	s.True(errors.Is(err, client.ErrConflict), "Signing up twice should fail with a conflict")
+
+	_, err = c.Login(ctx, contractClientUser, "wrong password")
+	s.True(errors.Is(err, client.ErrUnauthorized))
+	auth, err := c.Login(ctx, contractClientUser, contractPassword)
+	s.Require().NoError(err)
+	s.False(auth.TwoFactorRequired)
+	s.NotEmpty(c.Token())
+
+	me, etag, err := c.Me(ctx)
+	s.Require().NoError(err)
+	s.Equal(contractClientUser, me.Username)
+
+	patched, newETag, err := c.PatchMe(ctx, map[string]interface{}{"bio": "Written by the Go client"}, etag)
+	s.Require().NoError(err, "The session cookie and CSRF token should come from the cookie jar")
+	s.Equal("Written by the Go client", patched.Bio)
+	s.NotEqual(etag, newETag)
+	_, _, err = c.PatchMe(ctx, map[string]interface{}{"bio": nil}, etag)
+	s.True(errors.Is(err, client.ErrPreconditionFailed), "A stale ETag should be rejected")
+
+	updated, err := c.UpdateMe(ctx, &models.UpdateUserRequest{Name: "Client", Surname: "User"})
+	s.Require().NoError(err)
+	s.Equal("Client", updated.Name)
+
+	public, err := c.GetUser(ctx, contractClientUser)
+	s.Require().NoError(err)
+	s.Equal(me.PublicID, public.ID)
+	_, err = c.GetUser(ctx, "test_contract_nobody")
+	s.True(errors.Is(err, client.ErrNotFound))
+
+	page, err := c.SearchUsers(ctx, contractClientUser, "", 10)
+	s.Require().NoError(err)
+	s.NotEmpty(page.Users)
+	batch, err := c.LookupUsers(ctx, &models.BatchUserLookupRequest{Usernames: []string{contractClientUser, "test_contract_nobody"}})
+	s.Require().NoError(err)
+	s.Len(batch.Users, 1)
+	s.Equal([]string{"test_contract_nobody"}, batch.NotFound)
+
+	// A second client reuses the session as a bearer token.
+	bearer, err := client.New(s.server.URL, client.Options{Token: c.Token()})
+	s.Require().NoError(err)
+	s.Require().NoError(bearer.ChangePassword(ctx, contractPassword, contractPassword+"2"))
+
+	s.Require().NoError(c.Logout(ctx))
+	_, _, err = c.Me(ctx)
+	s.True(errors.Is(err, client.ErrUnauthorized), "The session should be gone after logout")
+
+	_, err = c.Login(ctx, contractClientUser, contractPassword+"2")
+	s.Require().NoError(err)
+	deleted, err := c.DeleteAccount(ctx, contractPassword+"2")
+	s.Require().NoError(err)
+	s.NotEmpty(deleted.PurgeAfter)
+
+	s.Empty(s.responseErrors, "Responses should match openapi.yaml")
+}
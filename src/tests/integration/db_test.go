@@ -1,17 +1,26 @@
 package integration
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 
+	"social-network/config"
+	"social-network/snctl/commands"
+	"social-network/user-service/api"
 	"social-network/user-service/models"
+	"social-network/user-service/password"
 	"social-network/user-service/repository"
+	"social-network/user-service/service"
 )
 
 type DBTestSuite struct {
@@ -19,6 +28,9 @@ type DBTestSuite struct {
 	db          *sql.DB
 	userRepo    *repository.UserRepository
 	sessionRepo *repository.SessionRepository
+	auditRepo   *repository.AuditRepository
+	phoneRepo   *repository.PhoneVerificationRepository
+	hasher      *password.BcryptHasher
 	testUsers   []*models.User
 }
 
@@ -42,6 +54,12 @@ func (s *DBTestSuite) SetupSuite() {
 	}
 	s.userRepo = repository.NewUserRepository(s.db)
 	s.sessionRepo = repository.NewSessionRepository(s.db)
+	s.auditRepo = repository.NewAuditRepository(s.db)
+	s.phoneRepo = repository.NewPhoneVerificationRepository(s.db)
+	s.hasher, err = password.NewBcryptHasher(bcrypt.MinCost)
+	if err != nil {
+		s.T().Fatalf("Failed to create password hasher: %v", err)
+	}
 	err = s.userRepo.Init()
 	if err != nil {
 		s.T().Fatalf("Failed to initialize user repository: %v", err)
@@ -50,6 +68,14 @@ func (s *DBTestSuite) SetupSuite() {
 	if err != nil {
 		s.T().Fatalf("Failed to initialize session repository: %v", err)
 	}
+	err = s.auditRepo.Init()
+	if err != nil {
+		s.T().Fatalf("Failed to initialize audit repository: %v", err)
+	}
+	err = s.phoneRepo.Init()
+	if err != nil {
+		s.T().Fatalf("Failed to initialize phone verification repository: %v", err)
+	}
 
 	s.cleanTestData()
 }
@@ -74,6 +100,10 @@ func (s *DBTestSuite) cleanTestData() {
 	if err != nil {
 		s.T().Logf("Warning: Failed to clean up test sessions: %v", err)
 	}
+	_, err = s.db.Exec("DELETE FROM audit_log WHERE target_username LIKE 'test_%'")
+	if err != nil {
+		s.T().Logf("Warning: Failed to clean up test audit entries: %v", err)
+	}
 	_, err = s.db.Exec("DELETE FROM users WHERE username LIKE 'test_%'")
 	if err != nil {
 		s.T().Logf("Warning: Failed to clean up test users: %v", err)
@@ -85,7 +115,7 @@ func (s *DBTestSuite) createTestUsers() {
 	for i := 1; i <= 3; i++ {
 		username := fmt.Sprintf("test_user_%d", i)
 		email := fmt.Sprintf("test%d@example.com", i)
-		password, err := models.HashPassword(fmt.Sprintf("password%d", i))
+		password, err := s.hasher.Hash(fmt.Sprintf("password%d", i))
 		s.Require().NoError(err, "Failed to hash password")
 		user := &models.User{
 			Username:    username,
@@ -94,11 +124,11 @@ func (s *DBTestSuite) createTestUsers() {
 			Name:        fmt.Sprintf("Test%d", i),
 			Surname:     fmt.Sprintf("User%d", i),
 			PhoneNumber: fmt.Sprintf("123456789%d", i),
-			Birthdate:   time.Date(1990, time.January, i, 0, 0, 0, 0, time.UTC),
+			Birthdate:   models.NewNullDate(models.NewDate(1990, time.January, i)),
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
 		}
-		err = s.userRepo.CreateUser(user)
+		err = s.userRepo.CreateUser(context.Background(), user)
 		s.Require().NoError(err, "Failed to create test user")
 		s.testUsers = append(s.testUsers, user)
 	}
@@ -112,11 +142,11 @@ func (s *DBTestSuite) TestUserRepositoryCreateUser() {
 		Name:        "Test",
 		Surname:     "Create",
 		PhoneNumber: "9876543210",
-		Birthdate:   time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		Birthdate:   models.NewNullDate(models.NewDate(1990, 1, 1)),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	err := s.userRepo.CreateUser(user)
+	err := s.userRepo.CreateUser(context.Background(), user)
 	s.NoError(err, "Failed to create user")
 	s.Greater(user.ID, int64(0), "User ID should be set after creation")
 	_, err = s.db.Exec("DELETE FROM users WHERE username = 'test_create_user'")
@@ -124,71 +154,358 @@ func (s *DBTestSuite) TestUserRepositoryCreateUser() {
 }
 
 func (s *DBTestSuite) TestUserRepositoryGetUserByUsername() {
-	user, err := s.userRepo.GetUserByUsername(s.testUsers[0].Username)
+	user, err := s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
 	s.NoError(err, "Failed to get user by username")
 	s.NotNil(user, "User should not be nil")
 	s.Equal(s.testUsers[0].Username, user.Username, "Username should match")
 	s.Equal(s.testUsers[0].Email, user.Email, "Email should match")
 
-	_, err = s.userRepo.GetUserByUsername("nonexistent_user")
+	_, err = s.userRepo.GetUserByUsername(context.Background(), "nonexistent_user")
 	s.Error(err, "Should get error for non-existent user")
 }
 
 func (s *DBTestSuite) TestUserRepositoryUserExists() {
-	exists, err := s.userRepo.UserExists(s.testUsers[0].Username)
+	exists, err := s.userRepo.UserExists(context.Background(), s.testUsers[0].Username)
 	s.NoError(err, "Error checking if user exists")
 	s.True(exists, "User should exist")
 
-	exists, err = s.userRepo.UserExists("nonexistent_user")
+	exists, err = s.userRepo.UserExists(context.Background(), "nonexistent_user")
 	s.NoError(err, "Error checking if non-existent user exists")
 	s.False(exists, "Non-existent user should not exist")
 }
 
 func (s *DBTestSuite) TestUserRepositoryEmailExists() {
-	exists, err := s.userRepo.EmailExists(s.testUsers[0].Email)
+	exists, err := s.userRepo.EmailExists(context.Background(), s.testUsers[0].Email)
 	s.NoError(err, "Error checking if email exists")
 	s.True(exists, "Email should exist")
 
-	exists, err = s.userRepo.EmailExists("nonexistent@example.com")
+	exists, err = s.userRepo.EmailExists(context.Background(), "nonexistent@example.com")
 	s.NoError(err, "Error checking if non-existent email exists")
 	s.False(exists, "Non-existent email should not exist")
 }
 
 func (s *DBTestSuite) TestUserRepositoryUpdateUser() {
-	user, err := s.userRepo.GetUserByUsername(s.testUsers[0].Username)
+	user, err := s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
 	s.NoError(err, "Failed to get user for update")
 	user.Name = "Updated Name"
 	user.Surname = "Updated Surname"
 	user.Email = "updated@example.com"
 	user.PhoneNumber = "5555555555"
-	user.Birthdate = time.Date(1995, 5, 5, 0, 0, 0, 0, time.UTC)
+	user.Birthdate = models.NewNullDate(models.NewDate(1995, 5, 5))
 	user.UpdatedAt = time.Now()
-	err = s.userRepo.UpdateUser(user)
+	err = s.userRepo.UpdateUser(context.Background(), user)
 	s.NoError(err, "Failed to update user")
-	updatedUser, err := s.userRepo.GetUserByUsername(s.testUsers[0].Username)
+	updatedUser, err := s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
 	s.NoError(err, "Failed to get updated user")
 	s.Equal("Updated Name", updatedUser.Name, "Name should be updated")
 	s.Equal("Updated Surname", updatedUser.Surname, "Surname should be updated")
 	s.Equal("updated@example.com", updatedUser.Email, "Email should be updated")
 	s.Equal("5555555555", updatedUser.PhoneNumber, "Phone number should be updated")
-	expectedDate := time.Date(1995, 5, 5, 0, 0, 0, 0, time.UTC)
-	s.True(expectedDate.Equal(updatedUser.Birthdate.UTC()),
+	expectedDate := models.NewDate(1995, 5, 5)
+	s.True(updatedUser.Birthdate.Valid && expectedDate.Equal(updatedUser.Birthdate.Date),
 		"Birthdate should be updated. Expected %v, got %v",
-		expectedDate, updatedUser.Birthdate.UTC())
+		expectedDate, updatedUser.Birthdate.Date)
+
+	user.Birthdate = models.NullDate{}
+	err = s.userRepo.UpdateUser(context.Background(), user)
+	s.NoError(err, "Failed to clear birthdate")
+	updatedUser, err = s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
+	s.NoError(err, "Failed to get updated user")
+	s.False(updatedUser.Birthdate.Valid, "Birthdate should be cleared")
+}
+
+func (s *DBTestSuite) TestUserRepositoryUpdateUserIfUnmodified() {
+	user, err := s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
+	s.NoError(err, "Failed to get user for update")
+	stale := user.UpdatedAt
+
+	user.Surname = ""
+	updated, err := s.userRepo.UpdateUserIfUnmodified(context.Background(), user, stale)
+	s.NoError(err, "Failed to update user")
+	s.True(updated, "Update with current updated_at should succeed")
+	s.NotEqual(stale, user.UpdatedAt, "updated_at should be refreshed")
+
+	updatedUser, err := s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
+	s.NoError(err, "Failed to get updated user")
+	s.Equal("", updatedUser.Surname, "Surname should be cleared")
+	s.Equal(updatedUser.ETag(), user.ETag(), "Returned updated_at should match the stored one")
+
+	user.Name = "Lost Update"
+	updated, err = s.userRepo.UpdateUserIfUnmodified(context.Background(), user, stale)
+	s.NoError(err, "Conditional update should not error")
+	s.False(updated, "Update with stale updated_at should be rejected")
+}
+
+func (s *DBTestSuite) TestUserRepositoryPhoneVerification() {
+	username := s.testUsers[0].Username
+	number := s.testUsers[0].PhoneNumber
+
+	_, err := s.userRepo.MarkPhoneVerified(context.Background(), username, "+70000000000")
+	s.Error(err, "Verifying a number the user does not have should fail")
+
+	_, err = s.userRepo.MarkPhoneVerified(context.Background(), username, number)
+	s.NoError(err, "Failed to mark phone verified")
+
+	taken, err := s.userRepo.PhoneNumberVerifiedByOther(context.Background(), number, s.testUsers[1].Username)
+	s.NoError(err, "Failed to check phone uniqueness")
+	s.True(taken, "Verified number should be reported as taken for other users")
+
+	taken, err = s.userRepo.PhoneNumberVerifiedByOther(context.Background(), number, username)
+	s.NoError(err, "Failed to check phone uniqueness")
+	s.False(taken, "Own number should not count as taken")
+
+	user, err := s.userRepo.GetUserByUsername(context.Background(), username)
+	s.NoError(err, "Failed to get user")
+	s.NotNil(user.PhoneVerifiedAt, "Phone should be verified")
+
+	s.NoError(s.userRepo.UpdateUser(context.Background(), user), "Failed to update user")
+	user, err = s.userRepo.GetUserByUsername(context.Background(), username)
+	s.NoError(err, "Failed to get user")
+	s.NotNil(user.PhoneVerifiedAt, "Saving the same number should keep it verified")
+
+	user.PhoneNumber = "+79990000000"
+	s.NoError(s.userRepo.UpdateUser(context.Background(), user), "Failed to update user")
+	user, err = s.userRepo.GetUserByUsername(context.Background(), username)
+	s.NoError(err, "Failed to get user")
+	s.Nil(user.PhoneVerifiedAt, "Changing the number should drop verification")
+}
+
+func (s *DBTestSuite) TestPhoneVerificationRepository() {
+	user, err := s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
+	s.Require().NoError(err, "Failed to get user")
+
+	verification := &models.PhoneVerification{
+		UserID:      user.ID,
+		PhoneNumber: user.PhoneNumber,
+		CodeHash:    models.HashPhoneCode("123456"),
+		ExpiresAt:   time.Now().Add(models.PhoneCodeTTL),
+		CreatedAt:   time.Now(),
+	}
+	s.NoError(s.phoneRepo.Save(context.Background(), verification), "Failed to save verification")
+	s.NoError(s.phoneRepo.IncrementAttempts(context.Background(), user.ID), "Failed to increment attempts")
+
+	stored, err := s.phoneRepo.Get(context.Background(), user.ID)
+	s.NoError(err, "Failed to get verification")
+	s.Equal(1, stored.Attempts)
+	s.Equal(verification.CodeHash, stored.CodeHash)
+
+	s.NoError(s.phoneRepo.Save(context.Background(), verification), "Failed to replace verification")
+	stored, err = s.phoneRepo.Get(context.Background(), user.ID)
+	s.NoError(err, "Failed to get verification")
+	s.Equal(0, stored.Attempts, "A new code should reset attempts")
+
+	s.NoError(s.phoneRepo.Delete(context.Background(), user.ID), "Failed to delete verification")
+	_, err = s.phoneRepo.Get(context.Background(), user.ID)
+	s.Error(err, "Verification should be gone")
+}
+
+func (s *DBTestSuite) TestUserRepositoryReplacePasswordHash() {
+	user, err := s.userRepo.GetUserByUsername(context.Background(), s.testUsers[0].Username)
+	s.Require().NoError(err, "Failed to get user")
+
+	s.NoError(s.userRepo.ReplacePasswordHash(context.Background(), user.Username, "stale-hash", "new-hash"), "Failed to replace hash")
+	unchanged, err := s.userRepo.GetUserByUsername(context.Background(), user.Username)
+	s.NoError(err, "Failed to get user")
+	s.Equal(user.Password, unchanged.Password, "Hash should only be replaced if it still matches")
+
+	s.NoError(s.userRepo.ReplacePasswordHash(context.Background(), user.Username, user.Password, "new-hash"), "Failed to replace hash")
+	replaced, err := s.userRepo.GetUserByUsername(context.Background(), user.Username)
+	s.NoError(err, "Failed to get user")
+	s.Equal("new-hash", replaced.Password, "Hash should be replaced")
+	s.Equal(user.ETag(), replaced.ETag(), "Rehashing should not change the profile version")
+
+	longHash := "$argon2id$v=19$m=1048576,t=10,p=16$" + strings.Repeat("c2FsdA", 4) + "$" + strings.Repeat("aGFzaA", 8)
+	s.Greater(len(longHash), 100)
+	s.NoError(s.userRepo.ReplacePasswordHash(context.Background(), user.Username, "new-hash", longHash), "Long PHC strings should fit the password column")
+	replaced, err = s.userRepo.GetUserByUsername(context.Background(), user.Username)
+	s.NoError(err, "Failed to get user")
+	s.Equal(longHash, replaced.Password)
+}
+
+func (s *DBTestSuite) TestUserRepositorySoftDeleteAndPurge() {
+	username := s.testUsers[0].Username
+	_, err := s.sessionRepo.CreateSession(context.Background(), s.testUsers[0].ID, username, 1*time.Hour)
+	s.NoError(err, "Failed to create session for test")
+
+	_, err = s.userRepo.SoftDeleteUser(context.Background(), username)
+	s.NoError(err, "Failed to soft-delete user")
+	user, err := s.userRepo.GetUserByUsername(context.Background(), username)
+	s.NoError(err, "Soft-deleted user should still be readable")
+	s.NotNil(user.DeletedAt, "DeletedAt should be set after soft delete")
+
+	purged, err := s.userRepo.PurgeDeletedUsers(context.Background(), time.Now().Add(-1*time.Hour))
+	s.NoError(err, "Failed to purge deleted users")
+	s.Equal(int64(0), purged, "Users inside the grace period should not be purged")
+
+	err = s.userRepo.RestoreUser(context.Background(), username)
+	s.NoError(err, "Failed to restore user")
+	user, err = s.userRepo.GetUserByUsername(context.Background(), username)
+	s.NoError(err, "Failed to get restored user")
+	s.Nil(user.DeletedAt, "DeletedAt should be cleared after restore")
+
+	_, err = s.userRepo.SoftDeleteUser(context.Background(), username)
+	s.NoError(err, "Failed to soft-delete user again")
+	purged, err = s.userRepo.PurgeDeletedUsers(context.Background(), time.Now().Add(1*time.Hour))
+	s.NoError(err, "Failed to purge deleted users")
+	s.Equal(int64(1), purged, "Soft-deleted user should be purged")
+
+	var count int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE username = $1", username).Scan(&count)
+	s.NoError(err, "Failed to count sessions after purge")
+	s.Equal(0, count, "Sessions should be removed together with the user")
+}
+
+func (s *DBTestSuite) TestUserRepositoryRoleAndSuspension() {
+	username := s.testUsers[1].Username
+
+	err := s.userRepo.SetRole(context.Background(), username, models.RoleModerator)
+	s.NoError(err, "Failed to set role")
+	err = s.userRepo.SetSuspended(context.Background(), username, true)
+	s.NoError(err, "Failed to suspend user")
+	err = s.userRepo.SetPasswordResetRequired(context.Background(), username, true)
+	s.NoError(err, "Failed to require password reset")
+
+	user, err := s.userRepo.GetUserByUsername(context.Background(), username)
+	s.NoError(err, "Failed to get user")
+	s.Equal(models.RoleModerator, user.Role, "Role should be updated")
+	s.NotNil(user.SuspendedAt, "User should be suspended")
+	s.True(user.PasswordResetRequired, "Password reset should be required")
+
+	err = s.userRepo.UpdatePassword(context.Background(), username, "newhash")
+	s.NoError(err, "Failed to update password")
+	err = s.userRepo.SetSuspended(context.Background(), username, false)
+	s.NoError(err, "Failed to unsuspend user")
+	user, err = s.userRepo.GetUserByUsername(context.Background(), username)
+	s.NoError(err, "Failed to get user")
+	s.Nil(user.SuspendedAt, "User should be unsuspended")
+	s.False(user.PasswordResetRequired, "Password update should clear the reset flag")
+
+	err = s.userRepo.SetRole(context.Background(), "nonexistent_user", models.RoleAdmin)
+	s.Error(err, "Setting role of a non-existent user should fail")
+
+	users, err := s.userRepo.SearchUsers(context.Background(), "test_user_", 10, 0)
+	s.NoError(err, "Failed to search users")
+	s.Len(users, len(s.testUsers), "Search should find all test users")
+}
+
+func (s *DBTestSuite) TestUserRepositorySearchPublicUsers() {
+	users, err := s.userRepo.SearchPublicUsers(context.Background(), "test_user_", "", 2)
+	s.NoError(err, "Failed to search users")
+	s.Len(users, 2, "First page should be full")
+	s.Equal("test_user_1", users[0].Username, "Results should be ordered by username")
+
+	users, err = s.userRepo.SearchPublicUsers(context.Background(), "test_user_", users[1].Username, 2)
+	s.NoError(err, "Failed to fetch next page")
+	s.Len(users, 1, "Second page should contain the remaining user")
+	s.Equal("test_user_3", users[0].Username)
+
+	users, err = s.userRepo.SearchPublicUsers(context.Background(), "Test2", "", 10)
+	s.NoError(err, "Failed to search by name")
+	s.Len(users, 1, "Search should match by name")
+
+	users, err = s.userRepo.SearchPublicUsers(context.Background(), s.testUsers[0].Email, "", 10)
+	s.NoError(err, "Failed to search by email")
+	s.Empty(users, "Email must not be searchable")
+
+	err = s.userRepo.SetSuspended(context.Background(), s.testUsers[0].Username, true)
+	s.NoError(err, "Failed to suspend user")
+	users, err = s.userRepo.SearchPublicUsers(context.Background(), "test_user_", "", 10)
+	s.NoError(err, "Failed to search users")
+	s.Len(users, 2, "Suspended users should be hidden from the directory")
+}
+
+func (s *DBTestSuite) TestUserRepositoryGetPublicUsersBatch() {
+	users, err := s.userRepo.GetPublicUsersBatch(context.Background(),
+		[]string{s.testUsers[0].Username, "nonexistent_user"},
+		[]string{s.testUsers[1].PublicID, "not-a-uuid"},
+	)
+	s.NoError(err, "Failed to look up users")
+	s.Len(users, 2, "Existing users should be found by username and by id")
+	s.Equal(s.testUsers[0].PublicID, users[0].ID, "Public ID should be returned")
+	s.Equal(s.testUsers[1].Username, users[1].Username)
+
+	users, err = s.userRepo.GetPublicUsersBatch(context.Background(),
+		nil, []string{strings.ToUpper(s.testUsers[1].PublicID)})
+	s.NoError(err, "Failed to look up users")
+	s.Len(users, 1, "Public IDs should match in any letter case")
+
+	err = s.userRepo.SetSuspended(context.Background(), s.testUsers[0].Username, true)
+	s.NoError(err, "Failed to suspend user")
+	users, err = s.userRepo.GetPublicUsersBatch(context.Background(),
+		[]string{s.testUsers[0].Username}, []string{s.testUsers[1].PublicID})
+	s.NoError(err, "Failed to look up users")
+	s.Len(users, 1, "Suspended users should not be returned")
+	s.Equal(s.testUsers[1].Username, users[0].Username)
+}
+
+func (s *DBTestSuite) TestAuditRepositoryRecordAndList() {
+	target := s.testUsers[0]
+	s.NoError(s.auditRepo.Record(context.Background(), "test_admin", target, "suspend", "spam"))
+	s.NoError(s.auditRepo.Record(context.Background(), "test_admin", target, "unsuspend", ""))
+
+	entries, err := s.auditRepo.ListForUser(context.Background(), target.ID, 10, 0)
+	s.NoError(err, "Failed to list audit entries")
+	s.Len(entries, 2, "Both audit entries should be listed")
+	s.Equal("unsuspend", entries[0].Action, "Newest entry should come first")
+	s.Equal("spam", entries[1].Details, "Details should be stored")
+	s.Equal(target.Username, entries[0].TargetUsername)
+}
+
+func (s *DBTestSuite) TestAuditHistoryIsNotInheritedByReusedUsername() {
+	old := s.testUsers[0]
+	s.NoError(s.auditRepo.Record(context.Background(), "test_admin", old, "suspend", "spam"))
+
+	_, err := s.db.Exec("DELETE FROM users WHERE id = $1", old.ID)
+	s.Require().NoError(err, "Failed to purge user")
+	reused := &models.User{
+		Username:  old.Username,
+		Password:  old.Password,
+		Email:     "test_reused@example.com",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	s.Require().NoError(s.userRepo.CreateUser(context.Background(), reused), "Failed to create user")
+
+	entries, err := s.auditRepo.ListForUser(context.Background(), reused.ID, 10, 0)
+	s.NoError(err, "Failed to list audit entries")
+	s.Empty(entries, "A new account must not inherit the audit history of a purged one")
+}
+
+func (s *DBTestSuite) TestExportUserDataCoversAccountState() {
+	twoFactorRepo := repository.NewTwoFactorRepository(s.db)
+	s.Require().NoError(twoFactorRepo.Init(), "Failed to initialize two-factor repository")
+	userService := service.NewUserService(s.userRepo, s.sessionRepo, twoFactorRepo, s.auditRepo, nil, s.hasher, time.Hour)
+
+	user := s.testUsers[0]
+	s.Require().NoError(s.userRepo.SetRole(context.Background(), user.Username, models.RoleModerator))
+	s.Require().NoError(s.userRepo.SetSuspended(context.Background(), user.Username, true))
+	s.Require().NoError(s.userRepo.SetPasswordResetRequired(context.Background(), user.Username, true))
+	s.Require().NoError(s.auditRepo.Record(context.Background(), "test_admin", user, "suspend", "spam"))
+
+	export, err := userService.ExportUserData(context.Background(), user.Username)
+	s.Require().NoError(err, "Failed to export user data")
+	s.Equal(user.PublicID, export.Profile.PublicID)
+	s.Equal(models.RoleModerator, export.Profile.Role)
+	s.NotNil(export.Profile.SuspendedAt, "Suspension should be exported")
+	s.True(export.Profile.PasswordResetRequired, "Forced password reset should be exported")
+	if s.Len(export.AuditLog, 1, "Audit entries about the user should be exported") {
+		s.Equal("suspend", export.AuditLog[0].Action)
+		s.Equal("spam", export.AuditLog[0].Details)
+	}
 }
 
 func (s *DBTestSuite) TestSessionRepositoryCreateSession() {
 	userID := s.testUsers[0].ID
 	username := s.testUsers[0].Username
 	duration := 1 * time.Hour
-	session, err := s.sessionRepo.CreateSession(userID, username, duration)
+	session, err := s.sessionRepo.CreateSession(context.Background(), userID, username, duration)
 	s.NoError(err, "Failed to create session")
 	s.NotNil(session, "Session should not be nil")
 	s.Equal(username, session.Username, "Session username should match")
 	s.NotEmpty(session.SessionToken, "Session token should not be empty")
 	s.True(session.ExpiresAt.After(time.Now()), "Session expiry should be in the future")
 
-	retrievedSession, err := s.sessionRepo.GetSessionByToken(session.SessionToken)
+	retrievedSession, err := s.sessionRepo.GetSessionByToken(context.Background(), session.SessionToken)
 	s.NoError(err, "Failed to retrieve session")
 	s.Equal(session.SessionToken, retrievedSession.SessionToken, "Session tokens should match")
 	s.Equal(username, retrievedSession.Username, "Session username should match")
@@ -197,27 +514,27 @@ func (s *DBTestSuite) TestSessionRepositoryCreateSession() {
 func (s *DBTestSuite) TestSessionRepositoryGetSessionByToken() {
 	userID := s.testUsers[0].ID
 	username := s.testUsers[0].Username
-	session, err := s.sessionRepo.CreateSession(userID, username, 1*time.Hour)
+	session, err := s.sessionRepo.CreateSession(context.Background(), userID, username, 1*time.Hour)
 	s.NoError(err, "Failed to create session for test")
 
-	retrievedSession, err := s.sessionRepo.GetSessionByToken(session.SessionToken)
+	retrievedSession, err := s.sessionRepo.GetSessionByToken(context.Background(), session.SessionToken)
 	s.NoError(err, "Failed to get session by token")
 	s.NotNil(retrievedSession, "Session should not be nil")
 	s.Equal(session.SessionToken, retrievedSession.SessionToken, "Session tokens should match")
 	s.Equal(username, retrievedSession.Username, "Session username should match")
 
-	_, err = s.sessionRepo.GetSessionByToken("nonexistent_token")
+	_, err = s.sessionRepo.GetSessionByToken(context.Background(), "nonexistent_token")
 	s.Error(err, "Should get error for non-existent token")
 }
 
 func (s *DBTestSuite) TestSessionRepositoryDeleteSession() {
 	userID := s.testUsers[0].ID
 	username := s.testUsers[0].Username
-	session, err := s.sessionRepo.CreateSession(userID, username, 1*time.Hour)
+	session, err := s.sessionRepo.CreateSession(context.Background(), userID, username, 1*time.Hour)
 	s.NoError(err, "Failed to create session for test")
-	err = s.sessionRepo.DeleteSession(session.SessionToken)
+	err = s.sessionRepo.DeleteSession(context.Background(), session.SessionToken)
 	s.NoError(err, "Failed to delete session")
-	_, err = s.sessionRepo.GetSessionByToken(session.SessionToken)
+	_, err = s.sessionRepo.GetSessionByToken(context.Background(), session.SessionToken)
 	s.Error(err, "Deleted session should not be retrievable")
 }
 
@@ -225,10 +542,10 @@ func (s *DBTestSuite) TestSessionRepositoryDeleteAllUserSessions() {
 	userID := s.testUsers[0].ID
 	username := s.testUsers[0].Username
 	for i := 0; i < 3; i++ {
-		_, err := s.sessionRepo.CreateSession(userID, username, 1*time.Hour)
+		_, err := s.sessionRepo.CreateSession(context.Background(), userID, username, 1*time.Hour)
 		s.NoError(err, "Failed to create session %d for test", i)
 	}
-	err := s.sessionRepo.DeleteAllUserSessions(username)
+	err := s.sessionRepo.DeleteAllUserSessions(context.Background(), username)
 	s.NoError(err, "Failed to delete all user sessions")
 
 	var count int
@@ -237,23 +554,70 @@ func (s *DBTestSuite) TestSessionRepositoryDeleteAllUserSessions() {
 	s.Equal(0, count, "All sessions should be deleted")
 }
 
+func (s *DBTestSuite) TestSessionRepositoryDeleteUserSession() {
+	ctx := context.Background()
+	owner := s.testUsers[0]
+	kept, err := s.sessionRepo.CreateSession(ctx, owner.ID, owner.Username, time.Hour)
+	s.Require().NoError(err, "Failed to create session")
+	revoked, err := s.sessionRepo.CreateSession(ctx, owner.ID, owner.Username, time.Hour)
+	s.Require().NoError(err, "Failed to create session")
+
+	err = s.sessionRepo.DeleteUserSession(ctx, s.testUsers[1].Username, revoked.ID)
+	s.Error(err, "Sessions of other users should not be deleted")
+
+	err = s.sessionRepo.DeleteUserSession(ctx, owner.Username, revoked.ID)
+	s.NoError(err, "Failed to delete session")
+	_, err = s.sessionRepo.GetSessionByToken(ctx, revoked.SessionToken)
+	s.Error(err, "Deleted session should be gone")
+	_, err = s.sessionRepo.GetSessionByToken(ctx, kept.SessionToken)
+	s.NoError(err, "Other sessions should be kept")
+
+	err = s.sessionRepo.DeleteUserSession(ctx, owner.Username, revoked.ID)
+	s.Error(err, "Deleting a missing session should fail")
+}
+
+func (s *DBTestSuite) TestSnctlSeedIsIdempotent() {
+	s.T().Setenv("SMS_PROVIDER", "log")
+	s.T().Setenv("BCRYPT_COST", "4")
+	s.T().Setenv("DB_PASSWORD", "unused")
+	cfg, err := config.LoadUserService(nil)
+	s.Require().NoError(err, "Failed to load config")
+	var out bytes.Buffer
+	app, err := commands.New(cfg, &out)
+	s.Require().NoError(err, "Failed to set up snctl")
+	defer api.SetPasswordPolicy(password.DefaultPolicy)
+	app.UseDB(s.db)
+
+	seed, _ := commands.Lookup("seed")
+	s.Require().NoError(seed.Run(context.Background(), app, []string{"-count", "3", "-prefix", "test_seed_", "-password", "Seed-passw0rd"}))
+	s.Contains(out.String(), "created 3 accounts, 0 already existed")
+
+	out.Reset()
+	s.Require().NoError(seed.Run(context.Background(), app, []string{"-count", "4", "-prefix", "test_seed_", "-password", "Seed-passw0rd"}))
+	s.Contains(out.String(), "created 1 accounts, 3 already existed", "Seeding again should only top up")
+
+	user, err := s.userRepo.GetUserByUsername(context.Background(), "test_seed_004")
+	s.Require().NoError(err, "Seeded user should exist")
+	s.True(s.hasher.Verify("Seed-passw0rd", user.Password), "Seeded users should be able to log in")
+}
+
 func (s *DBTestSuite) TestSessionRepositoryCleanExpiredSessions() {
 	userID := s.testUsers[0].ID
 	username := s.testUsers[0].Username
 
-	expiredSession, err := s.sessionRepo.CreateSession(userID, username, -1*time.Hour)
+	expiredSession, err := s.sessionRepo.CreateSession(context.Background(), userID, username, -1*time.Hour)
 	s.NoError(err, "Failed to create expired session")
 
-	validSession, err := s.sessionRepo.CreateSession(userID, username, 1*time.Hour)
+	validSession, err := s.sessionRepo.CreateSession(context.Background(), userID, username, 1*time.Hour)
 	s.NoError(err, "Failed to create valid session")
 
-	err = s.sessionRepo.CleanExpiredSessions()
+	err = s.sessionRepo.CleanExpiredSessions(context.Background())
 	s.NoError(err, "Failed to clean expired sessions")
 
-	_, err = s.sessionRepo.GetSessionByToken(expiredSession.SessionToken)
+	_, err = s.sessionRepo.GetSessionByToken(context.Background(), expiredSession.SessionToken)
 	s.Error(err, "Expired session should be deleted")
 
-	_, err = s.sessionRepo.GetSessionByToken(validSession.SessionToken)
+	_, err = s.sessionRepo.GetSessionByToken(context.Background(), validSession.SessionToken)
 	s.NoError(err, "Valid session should still exist")
 }
 
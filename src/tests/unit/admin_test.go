@@ -0,0 +1,84 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/repository"
+	"social-network/user-service/service"
+)
+
+func expectUserRow(mock sqlmock.Sqlmock, id int64, username, role string) {
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE username = ?").
+		WithArgs(username).
+		WillReturnRows(userRows(id, username, role, nil))
+}
+
+func userRows(id int64, username, role string, suspendedAt *time.Time) *sqlmock.Rows {
+	var suspended interface{}
+	if suspendedAt != nil {
+		suspended = *suspendedAt
+	}
+	return sqlmock.NewRows([]string{
+		"id", "username", "password", "email", "name", "surname", "birthdate",
+		"phone_number", "created_at", "updated_at", "deleted_at", "role",
+		"suspended_at", "password_reset_required", "public_id", "bio", "links",
+		"avatar_key", "avatar_url", "avatar_thumbnail_url", "phone_verified_at",
+	}).AddRow(
+		id, username, "hashedpassword", username+"@example.com", "", "",
+		time.Time{}, "", time.Now(), time.Now(), nil, role,
+		suspended, false, "5f0c6a4e-8e0f-4a57-9d3a-1f1f2b7c9e10", "", []byte("{}"),
+		"", "", "", nil,
+	)
+}
+
+func newAdminService(t *testing.T) (*service.AdminService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return service.NewAdminService(db,
+		repository.NewUserRepository(db),
+		repository.NewSessionRepository(db),
+		repository.NewAuditRepository(db),
+	), mock
+}
+
+func TestAdminActionsAreAudited(t *testing.T) {
+	admin, mock := newAdminService(t)
+
+	expectUserRow(mock, 1, "admin", "admin")
+	expectUserRow(mock, 7, "alice", "user")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET suspended_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM sessions WHERE username").WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("admin", int64(7), "alice", service.AuditActionSuspend, "spam", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, admin.SuspendUser(context.Background(), "admin", "alice", "spam"))
+	assert.Nil(t, mock.ExpectationsWereMet(), "The audit entry should be keyed by user id and committed with the action")
+}
+
+func TestAdminActionRollsBackWithoutAuditEntry(t *testing.T) {
+	admin, mock := newAdminService(t)
+
+	expectUserRow(mock, 1, "admin", "admin")
+	expectUserRow(mock, 7, "alice", "user")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET password_reset_required").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM sessions WHERE username").WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	err := admin.ForcePasswordReset(context.Background(), "admin", "alice")
+	assert.NotNil(t, err, "A failed audit insert should fail the action")
+	assert.Nil(t, mock.ExpectationsWereMet(), "The action should be rolled back")
+}
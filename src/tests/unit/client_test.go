@@ -0,0 +1,221 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/client"
+	"social-network/openapi"
+	"social-network/user-service/api"
+	"social-network/user-service/models"
+)
+
+// specServer answers every documented operation with a canned body. Requests
+// go through the spec validator and the CSRF middleware, so a client call
+// that drifts from openapi.yaml or forgets the CSRF token fails.
+func specServer(t *testing.T) (*httptest.Server, *[]openapi.Operation) {
+	validator, err := openapi.Load(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Responses are checked by the contract tests against the real handlers.
+	validator.OnResponseError(func(r *http.Request, err error) {})
+
+	var mu sync.Mutex
+	var operations []openapi.Operation
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		operation, ok := validator.Match(r)
+		if !ok {
+			http.Error(w, "not documented", http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		operations = append(operations, operation)
+		mu.Unlock()
+
+		response := map[string]interface{}{"username": "alice", "message": "ok"}
+		switch operation.Path {
+		case "/api/v1/auth/login", "/api/v1/auth/2fa/verify":
+			http.SetCookie(w, &http.Cookie{Name: "session_token", Value: "session-1", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "csrf_token", Value: api.CSRFToken("session-1"), Path: "/"})
+			response["token"] = "session-1"
+		case "/api/v1/users/me":
+			w.Header().Set("ETag", `"v1"`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if operation.Path == "/api/v1/auth/signin" {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+
+	server := httptest.NewServer(api.CSRFMiddleware(validator.Middleware(handler)))
+	t.Cleanup(server.Close)
+	return server, &operations
+}
+
+func TestClientRequestsMatchSpec(t *testing.T) {
+	server, operations := specServer(t)
+	c, err := client.New(server.URL, client.Options{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx := context.Background()
+
+	_, err = c.SignUp(ctx, &models.SignInRequest{Username: "alice", Email: "alice@example.com", Password: "Secret-passw0rd"})
+	assert.Nil(t, err)
+	auth, err := c.Login(ctx, "alice", "Secret-passw0rd")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "session-1", auth.Token)
+		assert.Equal(t, "session-1", c.Token())
+	}
+	_, err = c.VerifyTwoFactor(ctx, "challenge", "123456")
+	assert.Nil(t, err)
+
+	_, etag, err := c.Me(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, `"v1"`, etag)
+	_, err = c.UpdateMe(ctx, &models.UpdateUserRequest{Name: "Alice"})
+	assert.Nil(t, err, "Mutations should carry the CSRF token from the cookie jar")
+	_, _, err = c.PatchMe(ctx, map[string]interface{}{"bio": nil, "name": "Alice"}, etag)
+	assert.Nil(t, err)
+	assert.Nil(t, c.ChangePassword(ctx, "Secret-passw0rd", "Other-passw0rd1"))
+
+	_, err = c.GetUser(ctx, "bob")
+	assert.Nil(t, err)
+	_, err = c.SearchUsers(ctx, "bo", "", 10)
+	assert.Nil(t, err)
+	_, err = c.LookupUsers(ctx, &models.BatchUserLookupRequest{Usernames: []string{"bob"}})
+	assert.Nil(t, err)
+
+	_, err = c.DeleteAccount(ctx, "Other-passw0rd1")
+	assert.Nil(t, err)
+	assert.Nil(t, c.Logout(ctx))
+	assert.Empty(t, c.Token())
+
+	assert.Len(t, *operations, 12, "Every call should hit a documented operation")
+}
+
+func TestClientBearerToken(t *testing.T) {
+	var authorization, csrf string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		csrf = r.Header.Get(api.CSRFHeader)
+		w.Write([]byte(`{"message": "ok"}`))
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL, client.Options{Token: "service-token"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, c.ChangePassword(context.Background(), "a", "b"))
+	assert.Equal(t, "Bearer service-token", authorization)
+	assert.Empty(t, csrf, "Bearer requests don't need a CSRF token")
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"username": "bob"}`))
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL, client.Options{MaxRetries: 2})
+	if !assert.Nil(t, err) {
+		return
+	}
+	user, err := c.GetUser(context.Background(), "bob")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "bob", user.Username)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+
+	calls.Store(0)
+	_, err = c.LookupUsers(context.Background(), &models.BatchUserLookupRequest{Usernames: []string{"bob"}})
+	assert.True(t, errors.Is(err, client.ErrUnavailable))
+	assert.Equal(t, int32(1), calls.Load(), "POST requests should not be retried")
+}
+
+func TestClientEscapesPathSegments(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Write([]byte(`{"username": "x"}`))
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL+"/gateway/", client.Options{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, username := range []string{"john doe", "a/b", "Ёжик", "100%"} {
+		_, err := c.GetUser(context.Background(), username)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, []string{
+		"/gateway/api/v1/users/john%20doe",
+		"/gateway/api/v1/users/a%2Fb",
+		"/gateway/api/v1/users/%D0%81%D0%B6%D0%B8%D0%BA",
+		"/gateway/api/v1/users/100%25",
+	}, paths, "Usernames should be escaped exactly once")
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/users/ghost":
+			w.Header().Set("X-Request-ID", "req-1")
+			http.Error(w, "User not found", http.StatusNotFound)
+		case "/api/v1/users/me":
+			http.Error(w, "profile was modified", http.StatusPreconditionFailed)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGatewayTimeout)
+			w.Write([]byte(`{"error": "Upstream service timed out", "upstream": "user-service", "request_id": "req-2"}`))
+		}
+	}))
+	defer server.Close()
+
+	c, err := client.New(server.URL, client.Options{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx := context.Background()
+
+	_, err = c.GetUser(ctx, "ghost")
+	var apiErr *client.Error
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "User not found", apiErr.Message)
+		assert.Equal(t, "req-1", apiErr.RequestID)
+	}
+	assert.True(t, errors.Is(err, client.ErrNotFound))
+	assert.False(t, errors.Is(err, client.ErrUnauthorized))
+
+	_, _, err = c.PatchMe(ctx, map[string]interface{}{"name": "x"}, `"stale"`)
+	assert.True(t, errors.Is(err, client.ErrPreconditionFailed))
+
+	_, err = c.SearchUsers(ctx, "", "", 0)
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, "Upstream service timed out", apiErr.Message)
+		assert.Equal(t, "req-2", apiErr.RequestID)
+	}
+	assert.True(t, errors.Is(err, client.ErrUnavailable))
+
+	_, err = client.New("ftp://example.com", client.Options{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/proxy-service/cors"
+)
+
+func newTestCORSPolicy(origins ...string) http.Handler {
+	policy := cors.New(cors.Options{
+		AllowedOrigins:   origins,
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+		ExposedHeaders:   []string{"X-Request-ID"},
+		Rules: []cors.Rule{
+			{Prefix: "/auth/", Methods: []string{"POST"}, Headers: []string{"Content-Type"}},
+			{Prefix: "/users", Methods: []string{"GET", "PATCH"}, Headers: []string{"Content-Type", "If-Match"}},
+		},
+	})
+	return policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func preflight(handler http.Handler, path, origin, method, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler := newTestCORSPolicy("https://app.example.com")
+
+	rr := preflight(handler, "/users/me", "https://app.example.com", "PATCH", "content-type, if-match")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, PATCH", rr.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "600", rr.Header().Get("Access-Control-Max-Age"))
+
+	rr = preflight(handler, "/auth/login", "https://app.example.com", "DELETE", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code, "Methods outside the route rule should be refused")
+
+	rr = preflight(handler, "/auth/login", "https://app.example.com", "POST", "If-Match")
+	assert.Equal(t, http.StatusForbidden, rr.Code, "Headers outside the route rule should be refused")
+
+	rr = preflight(handler, "/users/me", "https://evil.example.com", "GET", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSActualRequests(t *testing.T) {
+	handler := newTestCORSPolicy("https://app.example.com/")
+
+	req := httptest.NewRequest("GET", "/users/alice", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Request-ID", rr.Header().Get("Access-Control-Expose-Headers"))
+	assert.Contains(t, rr.Header().Values("Vary"), "Origin")
+
+	req = httptest.NewRequest("POST", "/auth/login", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "Disallowed origins should be rejected")
+
+	req = httptest.NewRequest("POST", "http://gateway.local/auth/login", nil)
+	req.Header.Set("Origin", "http://gateway.local")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Same-origin requests should pass")
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users/alice", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "Requests without Origin are not cross-origin")
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	policy := cors.New(cors.Options{
+		AllowedOrigins: []string{"*"},
+		Rules:          []cors.Rule{{Prefix: "/users", Methods: []string{"GET"}}},
+	})
+	handler := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/users/alice", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Credentials"))
+}
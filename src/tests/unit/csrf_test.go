@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/api"
+)
+
+func csrfRequest(method, path, sessionToken, csrfToken string) int {
+	handler := api.CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(method, path, nil)
+	if sessionToken != "" {
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: sessionToken})
+	}
+	if csrfToken != "" {
+		req.Header.Set(api.CSRFHeader, csrfToken)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestCSRFToken(t *testing.T) {
+	assert.Equal(t, api.CSRFToken("session-a"), api.CSRFToken("session-a"))
+	assert.NotEqual(t, api.CSRFToken("session-a"), api.CSRFToken("session-b"), "Tokens should be bound to the session")
+	assert.NotContains(t, api.CSRFToken("session-a"), "session-a")
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	token := "session-token"
+
+	assert.Equal(t, http.StatusForbidden, csrfRequest("PUT", "/api/v1/users/me", token, ""), "Cookie-authenticated mutations need a CSRF token")
+	assert.Equal(t, http.StatusForbidden, csrfRequest("POST", "/api/v1/auth/logout", token, api.CSRFToken("other-session")))
+	assert.Equal(t, http.StatusOK, csrfRequest("POST", "/api/v1/auth/logout", token, api.CSRFToken(token)))
+	assert.Equal(t, http.StatusOK, csrfRequest("DELETE", "/api/v1/users/me", token, api.CSRFToken(token)))
+
+	assert.Equal(t, http.StatusOK, csrfRequest("GET", "/api/v1/users/me", token, ""), "Safe methods are not checked")
+	assert.Equal(t, http.StatusOK, csrfRequest("POST", "/api/v1/auth/login", token, ""), "Logging in again with a stale cookie should work")
+	assert.Equal(t, http.StatusOK, csrfRequest("POST", "/api/v1/users/batch", "", ""), "Requests without a session cookie are not checked")
+}
+
+func TestCSRFMiddlewareExemptsBearerAuth(t *testing.T) {
+	handler := api.CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("PUT", "/api/v1/users/me", nil)
+	req.Header.Set("Authorization", "Bearer session-token")
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "session-token"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,86 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/api"
+	"social-network/user-service/models"
+)
+
+func TestDateJSON(t *testing.T) {
+	data, err := json.Marshal(models.NewNullDate(models.NewDate(1990, time.March, 1)))
+	assert.Nil(t, err, "Failed to marshal date")
+	assert.Equal(t, `"1990-03-01"`, string(data))
+
+	data, err = json.Marshal(models.NullDate{})
+	assert.Nil(t, err, "Failed to marshal null date")
+	assert.Equal(t, "null", string(data), "Missing date should serialize as null, not a zero time")
+
+	var date models.NullDate
+	assert.NotNil(t, json.Unmarshal([]byte(`"01.03.1990"`), &date), "Non-ISO dates should be rejected")
+	assert.NotNil(t, json.Unmarshal([]byte(`"1990-02-30"`), &date), "Impossible dates should be rejected")
+}
+
+func TestOptionalDate(t *testing.T) {
+	var req models.UpdateUserRequest
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"name":"Test"}`), &req))
+	assert.False(t, req.Birthdate.Set, "Absent birthdate should leave the field unchanged")
+
+	req = models.UpdateUserRequest{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"birthdate":null}`), &req))
+	assert.True(t, req.Birthdate.Set, "Explicit null should be recorded")
+	assert.False(t, req.Birthdate.Valid, "Explicit null should clear the birthdate")
+
+	req = models.UpdateUserRequest{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"birthdate":"2000-12-31"}`), &req))
+	assert.True(t, req.Birthdate.Set && req.Birthdate.Valid)
+	assert.Equal(t, "2000-12-31", req.Birthdate.Date.String())
+}
+
+func TestNullDateScan(t *testing.T) {
+	var date models.NullDate
+
+	assert.Nil(t, date.Scan(time.Date(1990, 1, 2, 0, 0, 0, 0, time.FixedZone("X", 3600))))
+	assert.Equal(t, "1990-01-02", date.Date.String(), "Scanning should keep the calendar date")
+
+	assert.Nil(t, date.Scan(nil))
+	assert.False(t, date.Valid, "NULL should scan as an invalid date")
+
+	value, err := models.NewNullDate(models.NewDate(1990, 1, 2)).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "1990-01-02", value)
+}
+
+func TestBirthdateAgeValidation(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		birthdate models.NullDate
+		shouldErr bool
+	}{
+		{"Adult", models.NewNullDate(models.DateOf(now.AddDate(-30, 0, 0))), false},
+		{"Cleared", models.NullDate{}, false},
+		{"Too young", models.NewNullDate(models.DateOf(now.AddDate(-5, 0, 0))), true},
+		{"Future", models.NewNullDate(models.DateOf(now.AddDate(1, 0, 0))), true},
+		{"Too old", models.NewNullDate(models.NewDate(1800, 1, 1)), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := models.UpdateUserRequest{Birthdate: models.OptionalDate{NullDate: tc.birthdate, Set: true}}
+			err := api.Validate(req)
+			assert.Equal(t, tc.shouldErr, err != nil, "Unexpected validation result: %v", err)
+		})
+	}
+}
+
+func TestDateAge(t *testing.T) {
+	birthdate := models.NewDate(2000, time.June, 15)
+	assert.Equal(t, 23, birthdate.AgeAt(time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 24, birthdate.AgeAt(time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,126 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"social-network/health"
+	"social-network/user-service/repository"
+)
+
+func readiness(t *testing.T, checker *health.Checker) (int, health.Report) {
+	rr := httptest.NewRecorder()
+	checker.ReadinessHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	var report health.Report
+	err := json.Unmarshal(rr.Body.Bytes(), &report)
+	assert.Nil(t, err, "Readiness should return a JSON report")
+	return rr.Code, report
+}
+
+func TestHealthReadiness(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Add("database", func(ctx context.Context) error { return nil })
+
+	code, report := readiness(t, checker)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, report.Status)
+	assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+
+	checker.Add("schema", func(ctx context.Context) error { return errors.New("missing tables: users") })
+
+	code, report = readiness(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusUnavailable, report.Status)
+	assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, "missing tables: users", report.Checks["schema"].Error)
+}
+
+func TestHealthDrainingAndLiveness(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.SetDraining()
+
+	code, report := readiness(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusUnavailable, report.Checks["draining"].Status)
+
+	rr := httptest.NewRecorder()
+	checker.LivenessHandler(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "Liveness must not depend on readiness")
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	checker := health.NewChecker(10 * time.Millisecond)
+	checker.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, report := readiness(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
+
+func TestHealthUpstreamAggregation(t *testing.T) {
+	upstreamChecker := health.NewChecker(time.Second)
+	upstreamChecker.Add("database", func(ctx context.Context) error { return errors.New("connection refused") })
+	upstream := httptest.NewServer(http.HandlerFunc(upstreamChecker.ReadinessHandler))
+	defer upstream.Close()
+
+	checker := health.NewChecker(time.Second)
+	checker.AddUpstream("user-service", upstream.URL, &http.Client{})
+
+	code, report := readiness(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	userService := report.Checks["user-service"]
+	assert.Equal(t, health.StatusUnavailable, userService.Status)
+	assert.Equal(t, "connection refused", userService.Checks["database"].Error, "Upstream detail should be included")
+
+	upstream.Close()
+	_, report = readiness(t, checker)
+	assert.Equal(t, health.StatusUnavailable, report.Checks["user-service"].Status)
+	assert.NotEmpty(t, report.Checks["user-service"].Error)
+}
+
+func TestHealthStatusHidesDetails(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Add("database", func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.3.7:5432: connect: connection refused")
+	})
+
+	rr := httptest.NewRecorder()
+	checker.StatusHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.JSONEq(t, `{"status": "unavailable"}`, rr.Body.String(), "Only the overall status should be public")
+	assert.NotContains(t, rr.Body.String(), "10.0.3.7")
+
+	code, report := readiness(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, report.Checks["database"].Error, "10.0.3.7", "The internal report should keep the details")
+}
+
+func TestCheckSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT t FROM unnest").
+		WillReturnRows(sqlmock.NewRows([]string{"t"}))
+	assert.Nil(t, repository.CheckSchema(context.Background(), db))
+
+	mock.ExpectQuery("SELECT t FROM unnest").
+		WillReturnRows(sqlmock.NewRows([]string{"t"}).AddRow("sessions").AddRow("audit_log"))
+	err = repository.CheckSchema(context.Background(), db)
+	assert.NotNil(t, err)
+	assert.Equal(t, "missing tables: sessions, audit_log", err.Error())
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
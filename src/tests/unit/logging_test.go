@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/logging"
+)
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		err := json.Unmarshal([]byte(line), &entry)
+		assert.Nil(t, err, "Log line should be valid JSON")
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestLoggingRedactsSensitiveAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", "info")
+	assert.Nil(t, err)
+
+	header := http.Header{}
+	header.Set("Cookie", "session_token=abc")
+	header.Set("Authorization", "Bearer xyz")
+	header.Set("Accept", "application/json")
+
+	logger.Info("test",
+		"password", "hunter2",
+		"session_token", "abc",
+		"code", "123456",
+		"username", "testuser",
+		"headers", logging.Headers(header),
+	)
+
+	output := buf.String()
+	assert.NotContains(t, output, "hunter2")
+	assert.NotContains(t, output, "abc")
+	assert.NotContains(t, output, "xyz")
+	assert.NotContains(t, output, "123456")
+
+	lines := decodeLogLines(t, &buf)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "testuser", lines[0]["username"])
+	headers := lines[0]["headers"].(map[string]interface{})
+	assert.Equal(t, "application/json", headers["Accept"])
+	assert.Equal(t, "[REDACTED]", headers["Cookie"])
+}
+
+func TestLoggingRejectsInvalidSettings(t *testing.T) {
+	_, err := logging.New(&bytes.Buffer{}, "xml", "info")
+	assert.NotNil(t, err)
+
+	_, err = logging.New(&bytes.Buffer{}, "json", "verbose")
+	assert.NotNil(t, err)
+}
+
+func TestLoggingMiddlewareRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "json", "info")
+
+	var seen string
+	handler := logging.Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestIDFromContext(r.Context())
+		logging.AddAttrs(r.Context(), "user_id", "user-1")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest("GET", "/users/profile", nil)
+	req.Header.Set(logging.RequestIDHeader, "client-id-1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, "client-id-1", seen, "Well-formed request IDs should be reused")
+	assert.Equal(t, "client-id-1", rr.Header().Get(logging.RequestIDHeader))
+
+	lines := decodeLogLines(t, &buf)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "client-id-1", lines[0]["request_id"])
+	assert.Equal(t, "user-1", lines[0]["user_id"], "Attributes added downstream should reach the access log")
+	assert.Equal(t, float64(http.StatusTeapot), lines[0]["status"])
+
+	req = httptest.NewRequest("GET", "/users/profile", nil)
+	req.Header.Set(logging.RequestIDHeader, "bad id\nwith newline")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.NotEqual(t, "bad id\nwith newline", seen, "Malformed request IDs should be replaced")
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, rr.Header().Get(logging.RequestIDHeader))
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/media"
+)
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	assert.Nil(t, err, "Failed to encode test image")
+	return buf.Bytes()
+}
+
+func TestProcessAvatar(t *testing.T) {
+	processed, err := media.ProcessAvatar(encodeTestPNG(t, 300, 200))
+	assert.Nil(t, err, "Failed to process avatar")
+
+	large, err := jpeg.Decode(bytes.NewReader(processed.Large))
+	assert.Nil(t, err, "Large avatar should be a JPEG")
+	assert.Equal(t, image.Rect(0, 0, media.AvatarLargeSize, media.AvatarLargeSize), large.Bounds())
+
+	thumbnail, err := jpeg.Decode(bytes.NewReader(processed.Thumbnail))
+	assert.Nil(t, err, "Thumbnail should be a JPEG")
+	assert.Equal(t, image.Rect(0, 0, media.AvatarThumbnailSize, media.AvatarThumbnailSize), thumbnail.Bounds())
+}
+
+func TestProcessAvatarRejectsInvalidInput(t *testing.T) {
+	_, err := media.ProcessAvatar([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+	assert.NotNil(t, err, "Non-raster content should be rejected")
+	assert.Contains(t, err.Error(), "unsupported image type")
+
+	_, err = media.ProcessAvatar(encodeTestPNG(t, media.MaxAvatarDimension+1, 1))
+	assert.NotNil(t, err, "Oversized dimensions should be rejected")
+	assert.Contains(t, err.Error(), "too large")
+
+	_, err = media.ProcessAvatar(make([]byte, media.MaxAvatarBytes+1))
+	assert.NotNil(t, err, "Oversized upload should be rejected")
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := media.NewLocalBlobStore(t.TempDir(), "/media/")
+	assert.Nil(t, err, "Failed to create blob store")
+
+	err = store.Put("avatars/user/1-large.jpg", strings.NewReader("data"), "image/jpeg")
+	assert.Nil(t, err, "Failed to put blob")
+	assert.Equal(t, "/media/avatars/user/1-large.jpg", store.URL("avatars/user/1-large.jpg"))
+
+	blob, err := store.Open("avatars/user/1-large.jpg")
+	assert.Nil(t, err, "Failed to open blob")
+	content, _ := io.ReadAll(blob)
+	blob.Close()
+	assert.Equal(t, "data", string(content))
+
+	assert.Nil(t, store.Delete("avatars/user/1-large.jpg"), "Failed to delete blob")
+	_, err = store.Open("avatars/user/1-large.jpg")
+	assert.Equal(t, media.ErrBlobNotFound, err, "Deleted blob should not be found")
+
+	_, err = store.Open("../../etc/passwd")
+	assert.NotNil(t, err, "Path traversal should be rejected")
+}
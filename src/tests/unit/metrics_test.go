@@ -0,0 +1,50 @@
+package unit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"social-network/metrics"
+)
+
+func scrapeMetrics(t *testing.T) string {
+	rr := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	body, _ := io.ReadAll(rr.Body)
+	return string(body)
+}
+
+func TestMetricsMiddlewareUsesRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/metrics-test/{username}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["username"] == "missing" {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+	router.Use(metrics.Middleware)
+
+	for _, path := range []string{"/metrics-test/alice", "/metrics-test/bob", "/metrics-test/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	body := scrapeMetrics(t)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/metrics-test/{username}",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/metrics-test/{username}",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/metrics-test/{username}"} 3`)
+	assert.NotContains(t, body, "alice", "Raw paths must not become label values")
+}
+
+func TestLoginAttemptsCounter(t *testing.T) {
+	metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+
+	body := scrapeMetrics(t)
+	assert.Contains(t, body, `user_login_attempts_total{result="failure"}`)
+}
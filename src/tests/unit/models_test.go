@@ -9,16 +9,6 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestPasswordHashing(t *testing.T) {
-	password := "testPassword123"
-
-	hashedPassword, err := models.HashPassword(password)
-	assert.Nil(t, err, "Failed to hash password")
-	assert.NotEqual(t, hashedPassword, password, "Hashed password should be different from original")
-	assert.True(t, models.CheckPasswordHash(password, hashedPassword), "Password verification failed for correct password")
-	assert.False(t, models.CheckPasswordHash("wrongPassword", hashedPassword), "Password verification passed for incorrect password")
-}
-
 func TestUserModelValidation(t *testing.T) {
 	validate := func(s interface{}) error {
 		return api.Validate(s)
@@ -55,6 +45,15 @@ func TestUserModelValidation(t *testing.T) {
 			},
 			shouldErr: true,
 		},
+		{
+			name: "Reserved Username",
+			req: models.SignInRequest{
+				Username: "Profile", // collides with an API path
+				Email:    "test@example.com",
+				Password: "password123",
+			},
+			shouldErr: true,
+		},
 		{
 			name: "Short Password",
 			req: models.SignInRequest{
@@ -79,3 +78,17 @@ func TestUserModelValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestRolePermissions(t *testing.T) {
+	assert.False(t, models.RoleUser.Can(models.PermissionSearchUsers), "Regular users should not search users")
+	assert.True(t, models.RoleModerator.Can(models.PermissionSuspendUsers), "Moderators should suspend users")
+	assert.False(t, models.RoleModerator.Can(models.PermissionManageRoles), "Moderators should not manage roles")
+	assert.True(t, models.RoleAdmin.Can(models.PermissionManageRoles), "Admins should manage roles")
+	assert.False(t, models.Role("").Can(models.PermissionViewAudit), "Empty role should have no permissions")
+
+	_, err := models.ParseRole("superuser")
+	assert.NotNil(t, err, "Unknown role should be rejected")
+	role, err := models.ParseRole("moderator")
+	assert.Nil(t, err, "Known role should parse")
+	assert.Equal(t, models.RoleModerator, role)
+}
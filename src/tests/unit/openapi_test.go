@@ -0,0 +1,172 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"social-network/openapi"
+	"social-network/user-service/api"
+)
+
+const specPath = "../../openapi.yaml"
+
+// routeVariablePattern strips mux regexps such as {key:.+} down to {key}.
+var routeVariablePattern = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+// registeredOperations walks the user-service router. Handlers are never
+// called, so they don't need working services.
+func registeredOperations(t *testing.T) map[openapi.Operation]bool {
+	router := mux.NewRouter()
+	api.RegisterAPI(router,
+		api.NewUserHandler(nil),
+		api.NewAdminHandler(nil, nil),
+		api.NewMediaHandler(nil, nil),
+		api.NewPhoneHandler(nil),
+	)
+
+	operations := map[openapi.Operation]bool{}
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			operations[openapi.Operation{Method: method, Path: routeVariablePattern.ReplaceAllString(path, "{$1}")}] = true
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	return operations
+}
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	validator, err := openapi.Load(specPath)
+	if !assert.Nil(t, err, "openapi.yaml should be a valid OpenAPI document") {
+		return
+	}
+
+	registered := registeredOperations(t)
+	documented := map[openapi.Operation]bool{}
+	for _, operation := range validator.Operations() {
+		documented[operation] = true
+		assert.True(t, registered[operation], "%s is documented but not implemented", operation)
+	}
+	for operation := range registered {
+		assert.True(t, documented[operation], "%s is implemented but not documented", operation)
+	}
+}
+
+func writeTestSpec(t *testing.T) string {
+	path := t.TempDir() + "/spec.yaml"
+	spec := `openapi: 3.0.0
+info:
+  title: test
+  version: 1.0.0
+paths:
+  /items/{id}:
+    put:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name:
+                  type: string
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id]
+                properties:
+                  id:
+                    type: integer
+`
+	assert.Nil(t, os.WriteFile(path, []byte(spec), 0o600))
+	return path
+}
+
+func TestOpenAPIMiddleware(t *testing.T) {
+	validator, err := openapi.Load(writeTestSpec(t))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var responseErrors []error
+	validator.OnResponseError(func(r *http.Request, err error) { responseErrors = append(responseErrors, err) })
+
+	response := `{"id": 1}`
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	put := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := put("/items/1", `{"name": "thing"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, response, rr.Body.String())
+	assert.Empty(t, responseErrors)
+
+	rr = put("/items/1", `{"title": "thing"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Bodies missing required fields should be rejected")
+	assert.Contains(t, rr.Body.String(), "name")
+
+	rr = put("/items/abc", `{"name": "thing"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Path parameters should be checked")
+
+	response = `{"name": "thing"}`
+	rr = put("/items/1", `{"name": "thing"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "Invalid responses are reported, not replaced")
+	assert.Len(t, responseErrors, 1)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "Undocumented paths should pass through")
+
+	operation, ok := validator.Match(httptest.NewRequest("PUT", "/items/7", nil))
+	assert.True(t, ok)
+	assert.Equal(t, "PUT /items/{id}", operation.String())
+}
+
+func TestOpenAPIMatchesMediaKeysWithSlashes(t *testing.T) {
+	validator, err := openapi.Load(specPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	operation, ok := validator.Match(httptest.NewRequest("GET", "/media/avatars/abc/def-large.jpg", nil))
+	assert.True(t, ok)
+	assert.Equal(t, "GET /media/{key}", operation.String())
+
+	operation, ok = validator.Match(httptest.NewRequest("GET", "/api/v1/users/alice", nil))
+	assert.True(t, ok)
+	assert.Equal(t, "GET /api/v1/users/{username}", operation.String())
+}
@@ -0,0 +1,122 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/api"
+	"social-network/user-service/models"
+	"social-network/user-service/password"
+)
+
+func violatedRules(violations []password.Violation) []string {
+	rules := []string{}
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicyRules(t *testing.T) {
+	policy := password.DefaultPolicy
+	policy.MinCharacterClasses = 3
+
+	assert.Empty(t, policy.Check("Correct-Horse-42", "alice", "alice@example.com"))
+
+	assert.Equal(t, []string{password.RuleMinLength}, violatedRules(policy.Check("Ab1!", "alice")))
+	assert.Equal(t, []string{password.RuleCharacterClasses}, violatedRules(policy.Check("lowercaseonly42")))
+	assert.Equal(t, []string{password.RulePersonalInfo}, violatedRules(policy.Check("My-Alice-Pass1", "alice")),
+		"Username should be matched case-insensitively")
+	assert.Equal(t, []string{password.RulePersonalInfo}, violatedRules(policy.Check("bob.smith-Pass1", "bob_s", "bob.smith@example.com")),
+		"Email local part should be forbidden")
+	assert.Equal(t, []string{password.RuleMaxLength}, violatedRules(policy.Check(strings.Repeat("Aa1", 40))))
+
+	assert.Equal(t,
+		[]string{password.RuleMinLength, password.RuleCharacterClasses},
+		violatedRules(policy.Check("abc")),
+		"Every broken rule should be reported")
+
+	policy.ForbidPersonalInfo = false
+	assert.Empty(t, policy.Check("My-Alice-Pass1", "alice"))
+}
+
+func TestPasswordPolicyFitsHasher(t *testing.T) {
+	hasher, err := password.New(password.Config{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4, Argon2: password.DefaultArgon2Params})
+	if !assert.Nil(t, err) {
+		return
+	}
+	policy := password.DefaultPolicy
+
+	longest := strings.Repeat("Aa1-", 18)
+	assert.Empty(t, policy.Check(longest), "72 bytes should be accepted")
+	_, err = hasher.Hash(longest)
+	assert.Nil(t, err, "Every accepted password should be hashable")
+
+	assert.Equal(t, []string{password.RuleMaxLength}, violatedRules(policy.Check(longest+"x")),
+		"Passwords bcrypt can't hash should be rejected by the policy")
+	multiByte := strings.Repeat("Пароль-1", 6)
+	assert.Less(t, len([]rune(multiByte)), 72)
+	assert.Equal(t, []string{password.RuleMaxLength}, violatedRules(policy.Check(multiByte)),
+		"The limit should be measured in bytes, not characters")
+	_, err = hasher.Hash(multiByte)
+	assert.NotNil(t, err)
+
+	argon2Config := password.Config{Algorithm: password.AlgorithmArgon2id, BcryptCost: 4, Argon2: password.DefaultArgon2Params}
+	policy.MaxBytes = argon2Config.MaxPasswordBytes()
+	assert.Empty(t, policy.Check(multiByte), "Argon2id has no 72-byte limit")
+}
+
+func writeBreachedList(t *testing.T, lines ...string) string {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600)
+	assert.Nil(t, err, "Failed to write breached list")
+	return path
+}
+
+func TestBreachedList(t *testing.T) {
+	// SHA-1 of "password123" and "qwerty", in dump and lowercase formats.
+	path := writeBreachedList(t,
+		"# test list",
+		"CBFDAC6008F9CAB4083784CBD1874F76618D2A97:2418984",
+		"b1b3773a05c0ed0176787a4f1574ff0075f7521e",
+		"",
+	)
+
+	list, err := password.LoadBreachedList(path)
+	assert.Nil(t, err, "Failed to load breached list")
+	assert.Equal(t, 2, list.Len())
+	assert.True(t, list.Contains("password123"))
+	assert.True(t, list.Contains("qwerty"))
+	assert.False(t, list.Contains("Correct-Horse-42"))
+	assert.Len(t, list.Range("cbfda"), 1, "Ranges should be looked up by hash prefix")
+
+	_, err = password.LoadBreachedList(writeBreachedList(t, "not-a-hash"))
+	assert.NotNil(t, err, "Malformed lines should be rejected")
+
+	policy := password.DefaultPolicy
+	policy.Breached = list
+	assert.Equal(t, []string{password.RuleBreached}, violatedRules(policy.Check("password123")))
+}
+
+func TestValidatePasswordPolicyMessages(t *testing.T) {
+	err := api.Validate(models.SignInRequest{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Password: "testuser",
+	})
+	assert.NotNil(t, err, "Password equal to the username should be rejected")
+	assert.Contains(t, err.Error(), "password must contain at least 2 of")
+	assert.Contains(t, err.Error(), "password must not contain your username or email")
+
+	err = api.Validate(models.ChangePasswordRequest{
+		CurrentPassword: "old",
+		NewPassword:     "testuser-2024",
+		Username:        "testuser",
+	})
+	assert.NotNil(t, err, "New password containing the username should be rejected")
+	assert.Contains(t, err.Error(), "new_password must not contain your username or email")
+}
@@ -0,0 +1,98 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"social-network/user-service/password"
+)
+
+var testArgon2Params = password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestPasswordHashing(t *testing.T) {
+	bcryptHasher, err := password.NewBcryptHasher(bcrypt.MinCost)
+	assert.Nil(t, err, "Failed to create bcrypt hasher")
+	argon2Hasher, err := password.NewArgon2idHasher(testArgon2Params)
+	assert.Nil(t, err, "Failed to create argon2id hasher")
+
+	for _, hasher := range []password.Hasher{bcryptHasher, argon2Hasher} {
+		plain := "testPassword123"
+
+		hashedPassword, err := hasher.Hash(plain)
+		assert.Nil(t, err, "Failed to hash password")
+		assert.NotEqual(t, hashedPassword, plain, "Hashed password should be different from original")
+		assert.True(t, hasher.Supports(hashedPassword), "Hasher should recognize its own hashes")
+		assert.True(t, hasher.Verify(plain, hashedPassword), "Password verification failed for correct password")
+		assert.False(t, hasher.Verify("wrongPassword", hashedPassword), "Password verification passed for incorrect password")
+		assert.False(t, hasher.NeedsRehash(hashedPassword), "Fresh hash should not need a rehash")
+	}
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	hasher, err := password.NewArgon2idHasher(testArgon2Params)
+	assert.Nil(t, err, "Failed to create argon2id hasher")
+
+	hash, err := hasher.Hash("secret")
+	assert.Nil(t, err, "Failed to hash password")
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"), "Unexpected hash format: %s", hash)
+
+	other, err := hasher.Hash("secret")
+	assert.Nil(t, err, "Failed to hash password")
+	assert.NotEqual(t, hash, other, "Hashes should be salted")
+
+	assert.False(t, hasher.Verify("secret", "$argon2id$v=19$m=1024,t=1,p=1$garbage"))
+	assert.False(t, hasher.Verify("secret", "$argon2id$v=19$m=1024,t=1,p=0$c2FsdA$a2V5"))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weakBcrypt, _ := password.NewBcryptHasher(bcrypt.MinCost)
+	strongBcrypt, _ := password.NewBcryptHasher(bcrypt.MinCost + 1)
+	weakHash, err := weakBcrypt.Hash("secret")
+	assert.Nil(t, err, "Failed to hash password")
+
+	assert.True(t, strongBcrypt.NeedsRehash(weakHash), "Lower bcrypt cost should be upgraded")
+	assert.False(t, weakBcrypt.NeedsRehash(weakHash))
+
+	weakArgon2, _ := password.NewArgon2idHasher(testArgon2Params)
+	strongParams := testArgon2Params
+	strongParams.Iterations = 2
+	strongArgon2, _ := password.NewArgon2idHasher(strongParams)
+	argonHash, err := weakArgon2.Hash("secret")
+	assert.Nil(t, err, "Failed to hash password")
+	assert.True(t, strongArgon2.NeedsRehash(argonHash), "Fewer argon2 iterations should be upgraded")
+}
+
+func TestManagerMigratesAlgorithms(t *testing.T) {
+	bcryptHasher, _ := password.NewBcryptHasher(bcrypt.MinCost)
+	argon2Hasher, _ := password.NewArgon2idHasher(testArgon2Params)
+	manager := password.NewManager(argon2Hasher, bcryptHasher)
+
+	legacyHash, err := bcryptHasher.Hash("secret")
+	assert.Nil(t, err, "Failed to hash password")
+	assert.True(t, manager.Verify("secret", legacyHash), "Legacy bcrypt hashes should still verify")
+	assert.True(t, manager.NeedsRehash(legacyHash), "Non-preferred algorithm should be upgraded")
+
+	newHash, err := manager.Hash("secret")
+	assert.Nil(t, err, "Failed to hash password")
+	assert.True(t, argon2Hasher.Supports(newHash), "New hashes should use the preferred algorithm")
+	assert.False(t, manager.NeedsRehash(newHash))
+
+	assert.False(t, manager.Verify("secret", "plaintext"), "Unknown formats should never verify")
+}
+
+func TestNewHasherConfig(t *testing.T) {
+	_, err := password.New(password.Config{Algorithm: "md5", BcryptCost: bcrypt.MinCost, Argon2: testArgon2Params})
+	assert.NotNil(t, err, "Unknown algorithm should be rejected")
+
+	_, err = password.New(password.Config{Algorithm: password.AlgorithmBcrypt, BcryptCost: 40, Argon2: testArgon2Params})
+	assert.NotNil(t, err, "Out of range bcrypt cost should be rejected")
+
+	manager, err := password.New(password.Config{Algorithm: password.AlgorithmArgon2id, BcryptCost: bcrypt.MinCost, Argon2: testArgon2Params})
+	assert.Nil(t, err, "Failed to build hasher")
+	hash, err := manager.Hash("secret")
+	assert.Nil(t, err, "Failed to hash password")
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+}
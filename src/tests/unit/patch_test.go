@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/api"
+	"social-network/user-service/models"
+)
+
+func TestUserPatchRequestDecoding(t *testing.T) {
+	var patch models.UserPatchRequest
+	err := json.Unmarshal([]byte(`{"name":"Alice","surname":null,"links":["https://example.com"]}`), &patch)
+	assert.Nil(t, err, "Failed to decode merge patch")
+
+	assert.True(t, patch.Name.Set)
+	assert.False(t, patch.Name.Null)
+	assert.Equal(t, "Alice", patch.Name.Value)
+
+	assert.True(t, patch.Surname.Set, "Explicit null should be marked as set")
+	assert.True(t, patch.Surname.Null)
+
+	assert.False(t, patch.Bio.Set, "Omitted fields should stay unset")
+	assert.Equal(t, []string{"https://example.com"}, patch.Links.Value)
+}
+
+func TestUserPatchRequestValidation(t *testing.T) {
+	var patch models.UserPatchRequest
+	assert.Nil(t, json.Unmarshal([]byte(`{"name":null,"email":"a@example.com","links":null}`), &patch))
+	assert.Nil(t, api.Validate(patch), "Nulls and valid values should pass validation")
+
+	patch = models.UserPatchRequest{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"email":"not-an-email"}`), &patch))
+	assert.NotNil(t, api.Validate(patch), "Invalid email should be rejected")
+
+	patch = models.UserPatchRequest{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"links":["not a url"]}`), &patch))
+	assert.NotNil(t, api.Validate(patch), "Invalid link should be rejected")
+
+	for _, link := range []string{"javascript:alert(1)", "data:text/html,<script>alert(1)</script>", "ftp://example.com/file"} {
+		patch = models.UserPatchRequest{}
+		assert.Nil(t, json.Unmarshal([]byte(`{"links":[`+strconv.Quote(link)+`]}`), &patch))
+		assert.NotNil(t, api.Validate(patch), "Only http and https links should be accepted: %s", link)
+		err := api.Validate(models.UpdateUserRequest{Links: []string{link}})
+		if assert.NotNil(t, err, "Only http and https links should be accepted: %s", link) {
+			assert.Equal(t, "links[0] must be a valid http or https URL", err.Error())
+		}
+	}
+	assert.Nil(t, api.Validate(models.UpdateUserRequest{Links: []string{"https://example.com/me", "http://example.org"}}))
+}
+
+func TestETagMatches(t *testing.T) {
+	user := &models.User{UpdatedAt: time.Date(2024, time.May, 1, 12, 0, 0, 123456000, time.UTC)}
+	etag := user.ETag()
+
+	assert.True(t, models.ETagMatches(etag, etag))
+	assert.True(t, models.ETagMatches(`"other", `+etag, etag))
+	assert.True(t, models.ETagMatches("*", etag))
+	assert.False(t, models.ETagMatches(`"other"`, etag))
+	assert.False(t, models.ETagMatches("W/"+etag, etag), "Weak tags must not satisfy If-Match")
+
+	user.UpdatedAt = user.UpdatedAt.Add(time.Microsecond)
+	assert.NotEqual(t, etag, user.ETag(), "Any update should change the ETag")
+}
@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/logging"
+	"social-network/user-service/models"
+	"social-network/user-service/phone"
+	"social-network/user-service/sms"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	testCases := []struct {
+		raw      string
+		region   string
+		expected string
+	}{
+		{"+7 (999) 123-45-67", "RU", "+79991234567"},
+		{"8 999 123 45 67", "RU", "+79991234567"},
+		{"9991234567", "RU", "+79991234567"},
+		{"0044 20 7946 0958", "RU", "+442079460958"},
+		{"020 7946 0958", "GB", "+442079460958"},
+		{"(415) 555-2671", "US", "+14155552671"},
+		{"1-415-555-2671", "us", "+14155552671"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.raw, func(t *testing.T) {
+			number, err := phone.Normalize(tc.raw, tc.region)
+			assert.Nil(t, err, "Failed to normalize %q", tc.raw)
+			assert.Equal(t, tc.expected, number)
+		})
+	}
+}
+
+func TestNormalizePhoneNumberRejectsInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"12345",
+		"8 999 123 45",
+		"+7 999 123 45 67 890 12",
+		"+0 999 123 45 67",
+		"call me maybe",
+		"+7 999 123 45 67 ext 1",
+	}
+
+	for _, raw := range invalid {
+		_, err := phone.Normalize(raw, "RU")
+		assert.NotNil(t, err, "%q should be rejected", raw)
+	}
+
+	_, err := phone.Normalize("9991234567", "XX")
+	assert.NotNil(t, err, "National numbers need a known region")
+	assert.False(t, phone.IsKnownRegion("XX"))
+	assert.True(t, phone.IsKnownRegion("ru"))
+}
+
+func TestGeneratePhoneCode(t *testing.T) {
+	code, err := models.GeneratePhoneCode()
+	assert.Nil(t, err, "Failed to generate code")
+	assert.Len(t, code, models.PhoneCodeDigits)
+	for _, r := range code {
+		assert.True(t, r >= '0' && r <= '9', "Code should be numeric, got %q", code)
+	}
+
+	assert.Equal(t, models.HashPhoneCode(code), models.HashPhoneCode(" "+code+" "))
+	assert.NotEqual(t, models.HashPhoneCode("000000"), models.HashPhoneCode("000001"))
+}
+
+func TestSMSSenderSelection(t *testing.T) {
+	_, err := sms.New(sms.Config{})
+	assert.NotNil(t, err, "A missing provider must not fall back to the log sender")
+	_, err = sms.New(sms.Config{Provider: "carrier-pigeon"})
+	assert.NotNil(t, err)
+	_, err = sms.New(sms.Config{Provider: sms.ProviderWebhook})
+	assert.NotNil(t, err, "The webhook provider needs a URL")
+
+	sender, err := sms.New(sms.Config{Provider: sms.ProviderLog})
+	assert.Nil(t, err)
+	assert.IsType(t, &sms.LogSender{}, sender)
+}
+
+func TestLogSenderLogsMessagesForDevelopment(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", "debug")
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx := logging.WithLogger(context.Background(), logger)
+
+	assert.Nil(t, sms.NewLogSender().Send(ctx, "+79991234567", "Your verification code is 482915"))
+	assert.Contains(t, buf.String(), "482915", "The log provider should reveal codes so they can be entered in development")
+	assert.Contains(t, buf.String(), "development only")
+	assert.Contains(t, buf.String(), `"level":"WARN"`)
+	assert.NotContains(t, buf.String(), "+79991234567")
+	assert.Contains(t, buf.String(), "+799******67")
+
+	assert.Equal(t, "****", sms.MaskNumber("+123"))
+}
+
+func TestWebhookSender(t *testing.T) {
+	var received map[string]string
+	var authorization string
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sender, err := sms.New(sms.Config{Provider: sms.ProviderWebhook, WebhookURL: server.URL, WebhookToken: "gateway-token", Timeout: time.Second})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, sender.Send(context.Background(), "+79991234567", "Your verification code is 123456"))
+	assert.Equal(t, map[string]string{"to": "+79991234567", "message": "Your verification code is 123456"}, received)
+	assert.Equal(t, "Bearer gateway-token", authorization)
+
+	status = http.StatusInternalServerError
+	err = sender.Send(context.Background(), "+79991234567", "Your verification code is 123456")
+	if assert.NotNil(t, err, "Gateway failures should be reported") {
+		assert.NotContains(t, err.Error(), "123456", "Errors end up in logs and must not carry the message")
+	}
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/repository"
+)
+
+var publicUserRowColumns = []string{
+	"public_id", "username", "name", "surname", "bio", "avatar_url", "avatar_thumbnail_url",
+}
+
+func TestUserRepositoryReadReplica(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer replica.Close()
+
+	userRepo := repository.NewUserRepository(primary)
+	userRepo.SetReadReplica(replica)
+
+	t.Run("DirectoryReadsUseReplica", func(t *testing.T) {
+		replicaMock.ExpectQuery("SELECT (.+) FROM users").
+			WillReturnRows(sqlmock.NewRows(publicUserRowColumns).
+				AddRow("5f0c6a4e-8e0f-4a57-9d3a-1f1f2b7c9e10", "testuser", "Test", "User", "", "", ""))
+
+		users, err := userRepo.SearchPublicUsers(context.Background(), "", "", 20)
+		assert.Nil(t, err)
+		assert.Len(t, users, 1)
+		assert.Nil(t, replicaMock.ExpectationsWereMet())
+		assert.Nil(t, primaryMock.ExpectationsWereMet())
+	})
+
+	t.Run("FallsBackToPrimary", func(t *testing.T) {
+		replicaMock.ExpectQuery("SELECT (.+) FROM users").
+			WillReturnError(errors.New("connection refused"))
+		primaryMock.ExpectQuery("SELECT (.+) FROM users").
+			WillReturnRows(sqlmock.NewRows(publicUserRowColumns))
+
+		users, err := userRepo.GetPublicUsersBatch(context.Background(), []string{"ghost"}, nil)
+		assert.Nil(t, err)
+		assert.Empty(t, users)
+		assert.Nil(t, replicaMock.ExpectationsWereMet())
+		assert.Nil(t, primaryMock.ExpectationsWereMet())
+	})
+
+	t.Run("MissingUserFromReplica", func(t *testing.T) {
+		replicaMock.ExpectQuery("SELECT (.+) FROM users WHERE username = ?").
+			WithArgs("ghost").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		user, err := userRepo.GetUserByUsernameFromReplica(context.Background(), "ghost")
+		assert.Nil(t, user)
+		assert.EqualError(t, err, "user not found")
+		assert.Nil(t, replicaMock.ExpectationsWereMet())
+	})
+}
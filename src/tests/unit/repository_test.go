@@ -1,6 +1,7 @@
 package unit
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -41,11 +42,12 @@ func TestUserRepository(t *testing.T) {
 				sqlmock.AnyArg(), // CreatedAt
 				sqlmock.AnyArg(), // UpdatedAt
 			).
-			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "public_id"}).AddRow(1, "5f0c6a4e-8e0f-4a57-9d3a-1f1f2b7c9e10"))
 
-		err := userRepo.CreateUser(user)
+		err := userRepo.CreateUser(context.Background(), user)
 		assert.Nil(t, err, fmt.Sprintf("Expected no error, got %v", err))
 		assert.Equal(t, int(user.ID), int(1), fmt.Sprintf("Expected user ID to be 1, got %d", user.ID))
+		assert.NotEmpty(t, user.PublicID, "Expected public ID to be returned")
 		err = mock.ExpectationsWereMet()
 		assert.Nil(t, err, fmt.Sprintf("Unfulfilled expectations: %v", err))
 	})
@@ -56,21 +58,26 @@ func TestUserRepository(t *testing.T) {
 
 		rows := sqlmock.NewRows([]string{
 			"id", "username", "password", "email", "name", "surname", "birthdate",
-			"phone_number", "created_at", "updated_at",
+			"phone_number", "created_at", "updated_at", "deleted_at", "role",
+			"suspended_at", "password_reset_required", "public_id", "bio", "links",
+			"avatar_key", "avatar_url", "avatar_thumbnail_url", "phone_verified_at",
 		}).AddRow(
 			1, "testuser", "hashedpassword", "test@example.com", "Test", "User",
-			birthdate, "1234567890", createdAt, updatedAt,
+			birthdate, "1234567890", createdAt, updatedAt, nil, "user",
+			nil, false, "5f0c6a4e-8e0f-4a57-9d3a-1f1f2b7c9e10", "", []byte("{https://example.com}"),
+			"", "", "", nil,
 		)
 
 		mock.ExpectQuery("SELECT (.+) FROM users WHERE username = ?").
 			WithArgs("testuser").
 			WillReturnRows(rows)
 
-		user, err := userRepo.GetUserByUsername("testuser")
+		user, err := userRepo.GetUserByUsername(context.Background(), "testuser")
 
 		assert.Nil(t, err, fmt.Sprintf("Expected no error, got %v", err))
 		assert.NotNil(t, user, "Expected user to be returned, got nil")
 		assert.True(t, user.ID == 1 && user.Username == "testuser" && user.Email == "test@example.com", "User data doesn't match expected values")
+		assert.Equal(t, []string{"https://example.com"}, user.Links, "Links should be scanned from the array column")
 		err = mock.ExpectationsWereMet()
 		assert.Nil(t, err, fmt.Sprintf("Unfulfilled expectations: %v", err))
 	})
@@ -80,7 +87,7 @@ func TestUserRepository(t *testing.T) {
 			WithArgs("testuser").
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-		exists, err := userRepo.UserExists("testuser")
+		exists, err := userRepo.UserExists(context.Background(), "testuser")
 		assert.Nil(t, err, fmt.Sprintf("Expected no error, got %v", err))
 		assert.True(t, exists, "Expected user to exist, got false")
 		err = mock.ExpectationsWereMet()
@@ -106,7 +113,7 @@ func TestSessionRepository(t *testing.T) {
 			).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-		session, err := sessionRepo.CreateSession(1, "testuser", 24*time.Hour)
+		session, err := sessionRepo.CreateSession(context.Background(), 1, "testuser", 24*time.Hour)
 
 		assert.Nil(t, err, fmt.Sprintf("Expected no error, got %v", err))
 		assert.NotNil(t, session, "Expected session to be created, got nil")
@@ -131,7 +138,7 @@ func TestSessionRepository(t *testing.T) {
 			WithArgs(token).
 			WillReturnRows(rows)
 
-		session, err := sessionRepo.GetSessionByToken(token)
+		session, err := sessionRepo.GetSessionByToken(context.Background(), token)
 
 		assert.Nil(t, err, fmt.Sprintf("Expected no error, got %v", err))
 		assert.NotNil(t, session, "Expected session to be returned, got nil")
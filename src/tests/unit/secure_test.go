@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/proxy-service/secure"
+)
+
+func writeTestCertificate(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func servedCommonName(t *testing.T, reloader *secure.CertReloader) string {
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	assert.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "old.example.com")
+
+	reloader, err := secure.NewCertReloader(certFile, keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "old.example.com", servedCommonName(t, reloader))
+	assert.Contains(t, reloader.TLSConfig().NextProtos, "h2")
+
+	writeTestCertificate(t, dir, "new.example.com")
+	assert.Nil(t, reloader.Reload())
+	assert.Equal(t, "new.example.com", servedCommonName(t, reloader))
+
+	assert.Nil(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+	assert.NotNil(t, reloader.Reload())
+	assert.Equal(t, "new.example.com", servedCommonName(t, reloader), "A broken renewal should keep the old certificate")
+
+	_, err = secure.NewCertReloader(filepath.Join(dir, "missing.crt"), keyFile)
+	assert.NotNil(t, err)
+}
+
+func TestCertReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "old.example.com")
+	reloader, err := secure.NewCertReloader(certFile, keyFile)
+	assert.Nil(t, err)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go reloader.Watch(10*time.Millisecond, stop)
+
+	writeTestCertificate(t, dir, "new.example.com")
+	later := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(certFile, later, later))
+
+	assert.Eventually(t, func() bool {
+		return servedCommonName(t, reloader) == "new.example.com"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHSTS(t *testing.T) {
+	handler := secure.HSTS(365*24*time.Hour, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://example.com/users", nil))
+	assert.Empty(t, rr.Header().Get("Strict-Transport-Security"), "HSTS must not be sent over plain HTTP")
+
+	req := httptest.NewRequest("GET", "https://example.com/users", nil)
+	req.TLS = &tls.ConnectionState{}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", rr.Header().Get("Strict-Transport-Security"))
+}
+
+func TestSecureCookies(t *testing.T) {
+	header := http.Header{}
+	header.Add("Set-Cookie", "session_token=abc; Path=/; HttpOnly; SameSite=Strict")
+	header.Add("Set-Cookie", "csrf_token=def; Path=/; Max-Age=0")
+
+	secure.Cookies(header)
+
+	cookies := (&http.Response{Header: header}).Cookies()
+	assert.Len(t, cookies, 2)
+	for _, cookie := range cookies {
+		assert.True(t, cookie.Secure, cookie.Name)
+	}
+	assert.True(t, cookies[0].HttpOnly, "Other attributes should be kept")
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"social-network/config"
+	"social-network/snctl/commands"
+	"social-network/user-service/api"
+	"social-network/user-service/password"
+)
+
+// newSnctl returns the snctl commands working on a mock database.
+func newSnctl(t *testing.T) (*commands.App, sqlmock.Sqlmock, *bytes.Buffer) {
+	t.Setenv("DB_PASSWORD", "secret")
+	t.Setenv("SMS_PROVIDER", "log")
+	t.Setenv("BCRYPT_COST", "4")
+	cfg, err := config.LoadUserService(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	app, err := commands.New(cfg, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { api.SetPasswordPolicy(password.DefaultPolicy) })
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.UseDB(db)
+	t.Cleanup(app.Close)
+	return app, mock, &out
+}
+
+func runSnctl(app *commands.App, args ...string) error {
+	command, ok := commands.Lookup(args[0])
+	if !ok {
+		return errors.New("unknown command " + args[0])
+	}
+	return command.Run(context.Background(), app, args[1:])
+}
+
+func TestSnctlArgumentValidation(t *testing.T) {
+	app, mock, _ := newSnctl(t)
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"sessions"}, "usage: snctl sessions"},
+		{[]string{"sessions", "kill", "-username", "alice"}, "usage: snctl sessions"},
+		{[]string{"sessions", "revoke", "-id", "abc"}, "-username is required"},
+		{[]string{"sessions", "revoke", "-username", "alice"}, "exactly one of -id and -all is required"},
+		{[]string{"sessions", "revoke", "-username", "alice", "-id", "abc", "-all"}, "exactly one of -id and -all is required"},
+		{[]string{"create-user", "-username", "alice"}, "-email is required"},
+		{[]string{"create-user", "-username", "alice", "-email", "alice@example.com", "-role", "root"}, "role"},
+		{[]string{"reset-password"}, "-username is required"},
+		{[]string{"seed", "-count", "0"}, "-count must be between 1 and 10000"},
+		{[]string{"dump"}, "-username is required"},
+	}
+	for _, tc := range testCases {
+		err := runSnctl(app, tc.args...)
+		if assert.NotNil(t, err, "%v should be rejected", tc.args) {
+			assert.Contains(t, err.Error(), tc.expected, "%v", tc.args)
+		}
+	}
+
+	assert.True(t, errors.Is(runSnctl(app, "seed", "-h"), flag.ErrHelp), "-h should be reported, not treated as a failure")
+	assert.Nil(t, mock.ExpectationsWereMet(), "Invalid arguments must not touch the database")
+}
+
+func TestSnctlSessionsRevoke(t *testing.T) {
+	app, mock, out := newSnctl(t)
+
+	mock.ExpectExec("DELETE FROM sessions WHERE username = \\$1 AND id::text = \\$2").
+		WithArgs("alice", "abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, runSnctl(app, "sessions", "revoke", "-username", "alice", "-id", "abc"))
+	assert.Contains(t, out.String(), "revoked session abc of alice")
+
+	mock.ExpectExec("DELETE FROM sessions WHERE username = \\$1 AND id::text = \\$2").
+		WithArgs("alice", "other").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err := runSnctl(app, "sessions", "revoke", "-username", "alice", "-id", "other")
+	assert.NotNil(t, err, "Revoking a session of another user should fail")
+
+	mock.ExpectExec("DELETE FROM sessions WHERE username = \\$1$").
+		WithArgs("alice").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	assert.Nil(t, runSnctl(app, "sessions", "revoke", "-username", "alice", "-all"))
+	assert.Contains(t, out.String(), "revoked all sessions of alice")
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSnctlSeedSkipsExistingAccounts(t *testing.T) {
+	app, mock, out := newSnctl(t)
+
+	exists := func(username string, exists bool) {
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(username).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+	}
+	insert := func(username string) {
+		mock.ExpectQuery("INSERT INTO users").
+			WithArgs(username, sqlmock.AnyArg(), username+"@example.com", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "public_id"}).AddRow(1, "00000000-0000-0000-0000-000000000001"))
+	}
+	exists("demo_001", true)
+	exists("demo_002", false)
+	insert("demo_002")
+	exists("demo_003", false)
+	insert("demo_003")
+
+	assert.Nil(t, runSnctl(app, "seed", "-count", "3", "-prefix", "demo_"))
+	assert.Contains(t, out.String(), "created 2 accounts, 1 already existed")
+	assert.Nil(t, mock.ExpectationsWereMet(), "Only missing accounts should be created")
+}
+
+func TestSnctlGeneratePassword(t *testing.T) {
+	app, _, _ := newSnctl(t)
+	policy := password.DefaultPolicy
+
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		generated, err := app.GeneratePassword("alice", "alice@example.com")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, policy.Check(generated, "alice", "alice@example.com"), "Generated passwords should satisfy the policy")
+		assert.False(t, seen[generated], "Generated passwords should not repeat")
+		seen[generated] = true
+	}
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"social-network/config"
+	"social-network/tracing"
+)
+
+func TestTracingPropagatesThroughProxy(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	_, err := tracing.Setup(context.Background(), "test", tracing.Options{Exporter: tracing.ExporterNone})
+	assert.Nil(t, err)
+
+	var upstreamTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = tracing.Transport(http.DefaultTransport)
+
+	router := mux.NewRouter()
+	router.PathPrefix("/users/").Handler(proxy)
+	router.Use(tracing.Middleware)
+	handler := tracing.Handler(router, "test")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/users/testuser", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.HasPrefix(upstreamTraceparent, "00-"+traceID+"-"),
+		"Upstream should continue the caller's trace, got %q", upstreamTraceparent)
+
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		assert.Equal(t, traceID, span.SpanContext.TraceID().String())
+		names = append(names, span.Name)
+	}
+	assert.Contains(t, names, "GET /users/", "Server span should be named after the route template")
+}
+
+func TestTracingConfigValidation(t *testing.T) {
+	valid := config.TracingConfig{Exporter: "otlp", SampleRatio: 0.5}
+	assert.Nil(t, valid.Validate())
+
+	invalid := config.TracingConfig{Exporter: "jaeger", SampleRatio: 2}
+	err := invalid.Validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "tracing.exporter")
+	assert.Contains(t, err.Error(), "tracing.sample_ratio")
+
+	missingFile := config.TracingConfig{Exporter: "file", SampleRatio: 1}
+	assert.NotNil(t, missingFile.Validate())
+}
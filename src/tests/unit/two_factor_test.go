@@ -0,0 +1,74 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/models"
+)
+
+// RFC 6238 appendix B test secret "12345678901234567890" in base32.
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+	}
+
+	for _, tc := range tests {
+		code, err := models.TOTPCode(rfcTOTPSecret, models.TOTPStep(time.Unix(tc.unix, 0)), 8)
+		assert.Nil(t, err, "Failed to generate TOTP code")
+		assert.Equal(t, tc.expected, code, "Unexpected TOTP code at %d", tc.unix)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	step, ok := models.ValidateTOTPCode(rfcTOTPSecret, "287082", now)
+	assert.True(t, ok, "Current code should be accepted")
+	assert.Equal(t, int64(1), step, "Matched step should be returned")
+
+	_, ok = models.ValidateTOTPCode(rfcTOTPSecret, "287082", now.Add(30*time.Second))
+	assert.True(t, ok, "Code from the previous step should be accepted")
+
+	_, ok = models.ValidateTOTPCode(rfcTOTPSecret, "287082", now.Add(5*time.Minute))
+	assert.False(t, ok, "Stale code should be rejected")
+
+	_, ok = models.ValidateTOTPCode(rfcTOTPSecret, "000000", now)
+	assert.False(t, ok, "Wrong code should be rejected")
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	secret, err := models.GenerateTOTPSecret()
+	assert.Nil(t, err, "Failed to generate TOTP secret")
+	assert.Len(t, secret, 32, "20-byte secret should encode to 32 base32 characters")
+
+	uri := models.TOTPURI("SocialNetwork", "testuser", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/SocialNetwork:testuser?"), "Unexpected otpauth URI: %s", uri)
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := models.GenerateRecoveryCodes(models.RecoveryCodeCount)
+	assert.Nil(t, err, "Failed to generate recovery codes")
+	assert.Len(t, codes, models.RecoveryCodeCount)
+
+	code := codes[0]
+	assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`, code,
+		"Recovery codes should carry 80 random bits")
+	assert.Equal(t, models.HashRecoveryCode(code), models.HashRecoveryCode(strings.ToUpper(code)),
+		"Recovery code hash should ignore case")
+	assert.Equal(t, models.HashRecoveryCode(code), models.HashRecoveryCode(strings.ReplaceAll(code, "-", "")),
+		"Recovery code hash should ignore dashes")
+	assert.NotEqual(t, models.HashRecoveryCode(codes[0]), models.HashRecoveryCode(codes[1]))
+}
@@ -0,0 +1,192 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/proxy-service/upstream"
+)
+
+func testUpstreamOptions() upstream.Options {
+	return upstream.Options{
+		DialTimeout:           time.Second,
+		ResponseHeaderTimeout: time.Second,
+		MaxRetries:            2,
+		RetryBackoff:          time.Millisecond,
+		BreakerFailures:       3,
+		BreakerOpenTimeout:    time.Minute,
+	}
+}
+
+func newTestGateway(t *testing.T, backend *httptest.Server, opts upstream.Options) *httptest.Server {
+	target, err := url.Parse(backend.URL)
+	assert.Nil(t, err)
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = upstream.NewTransport("test", opts, nil)
+	proxy.ErrorHandler = upstream.ErrorHandler("test")
+
+	gateway := httptest.NewServer(proxy)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	breaker := upstream.NewBreaker(2, 20*time.Millisecond)
+	var transitions []bool
+	breaker.OnStateChange(func(open bool) { transitions = append(transitions, open) })
+
+	assert.Nil(t, breaker.Allow())
+	breaker.Record(false)
+	assert.Nil(t, breaker.Allow(), "One failure should not open the circuit")
+	breaker.Record(false)
+	err := breaker.Allow()
+	assert.ErrorIs(t, err, upstream.ErrCircuitOpen)
+	var openErr *upstream.CircuitOpenError
+	if assert.ErrorAs(t, err, &openErr) {
+		assert.True(t, openErr.RetryAfter > 0 && openErr.RetryAfter <= 20*time.Millisecond, "RetryAfter should be the time left: %v", openErr.RetryAfter)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(t, breaker.Allow(), "A probe should be let through after the open timeout")
+	assert.ErrorIs(t, breaker.Allow(), upstream.ErrCircuitOpen, "Only one probe at a time")
+
+	breaker.Record(false)
+	assert.ErrorIs(t, breaker.Allow(), upstream.ErrCircuitOpen, "A failed probe should reopen the circuit")
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(t, breaker.Allow())
+	breaker.Record(true)
+	assert.Nil(t, breaker.Allow())
+	assert.Nil(t, breaker.Allow(), "A successful probe should close the circuit")
+
+	assert.Equal(t, []bool{true, false}, transitions)
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	breaker := upstream.NewBreaker(2, time.Minute)
+
+	breaker.Record(false)
+	breaker.Record(true)
+	breaker.Record(false)
+	assert.Nil(t, breaker.Allow(), "Only consecutive failures should open the circuit")
+}
+
+func TestTransportRetriesIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	gateway := newTestGateway(t, backend, testUpstreamOptions())
+
+	resp, err := http.Get(gateway.URL + "/users/alice")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestTransportDoesNotRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	gateway := newTestGateway(t, backend, testUpstreamOptions())
+
+	resp, err := http.Post(gateway.URL+"/auth/signup", "application/json", strings.NewReader(`{}`))
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "The upstream response should be passed through")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func decodeUpstreamError(t *testing.T, resp *http.Response) map[string]string {
+	defer resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var body map[string]string
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+func TestTransportTimeoutReturnsGatewayTimeout(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	opts := testUpstreamOptions()
+	opts.ResponseHeaderTimeout = 20 * time.Millisecond
+	opts.MaxRetries = 0
+	gateway := newTestGateway(t, backend, opts)
+
+	resp, err := http.Get(gateway.URL + "/users/alice")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	body := decodeUpstreamError(t, resp)
+	assert.Equal(t, "test", body["upstream"])
+	assert.NotEmpty(t, body["error"])
+}
+
+func TestTransportOpenCircuitReturnsServiceUnavailable(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backendURL := backend.URL
+	backend.Close()
+
+	target, err := url.Parse(backendURL)
+	assert.Nil(t, err)
+	opts := testUpstreamOptions()
+	opts.MaxRetries = 0
+	opts.BreakerFailures = 1
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = upstream.NewTransport("test", opts, nil)
+	proxy.ErrorHandler = upstream.ErrorHandler("test")
+	gateway := httptest.NewServer(proxy)
+	defer gateway.Close()
+
+	resp, err := http.Get(gateway.URL + "/users/alice")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode, "A refused connection should be a bad gateway")
+	decodeUpstreamError(t, resp)
+
+	resp, err = http.Get(gateway.URL + "/users/alice")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	assert.Nil(t, err)
+	assert.True(t, retryAfter > 55 && retryAfter <= 60, "Retry-After should be the time left on the breaker, got %d", retryAfter)
+	body := decodeUpstreamError(t, resp)
+	assert.Equal(t, "test", body["upstream"])
+}
+
+func TestErrorHandlerIgnoresCanceledRequests(t *testing.T) {
+	rr := httptest.NewRecorder()
+	upstream.ErrorHandler("test")(rr, httptest.NewRequest("GET", "/users/alice", nil), fmt.Errorf("proxy: %w", context.Canceled))
+	assert.Empty(t, rr.Body.String())
+}
@@ -0,0 +1,61 @@
+package unit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"social-network/user-service/models"
+	"social-network/user-service/repository"
+	"social-network/user-service/service"
+)
+
+func newUserService(t *testing.T) (*service.UserService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return service.NewUserService(
+		repository.NewUserRepository(db),
+		repository.NewSessionRepository(db),
+		repository.NewTwoFactorRepository(db),
+		repository.NewAuditRepository(db),
+		nil, nil, time.Hour,
+	), mock
+}
+
+func TestBatchLookupMatchesUppercaseIDs(t *testing.T) {
+	users, mock := newUserService(t)
+
+	const id = "5f0c6a4e-8e0f-4a57-9d3a-1f1f2b7c9e10"
+	mock.ExpectQuery("SELECT (.+) FROM users").
+		WithArgs(sqlmock.AnyArg(), `{"`+id+`"}`).
+		WillReturnRows(sqlmock.NewRows(publicUserRowColumns).AddRow(id, "alice", "", "", "", "", ""))
+
+	response, err := users.BatchLookupUsers(context.Background(), &models.BatchUserLookupRequest{
+		IDs: []string{strings.ToUpper(id)},
+	})
+	if assert.Nil(t, err) {
+		assert.Len(t, response.Users, 1)
+		assert.Empty(t, response.NotFound, "An uppercase id of an existing user should be found")
+	}
+}
+
+func TestPublicProfileHidesSuspendedUsers(t *testing.T) {
+	users, mock := newUserService(t)
+
+	suspendedAt := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE username = ?").
+		WithArgs("alice").
+		WillReturnRows(userRows(7, "alice", "user", &suspendedAt))
+
+	_, err := users.GetUserPublicProfile(context.Background(), "alice")
+	if assert.NotNil(t, err, "Suspended accounts should not have a public profile") {
+		assert.Contains(t, err.Error(), "not found")
+	}
+}
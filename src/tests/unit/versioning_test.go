@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"social-network/proxy-service/versioning"
+)
+
+func newTestMapper() *versioning.Mapper {
+	return versioning.New(versioning.Options{
+		DeprecatedAt: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+		Sunset:       time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Routes: []versioning.Route{
+			{Legacy: "/auth/", Current: "/api/v1/auth/"},
+			{Legacy: "/users/profile", Current: "/api/v1/users/me"},
+			{Legacy: "/users", Current: "/api/v1/users"},
+			{Legacy: "/users/", Current: "/api/v1/users/"},
+		},
+	})
+}
+
+func TestVersioningRewrite(t *testing.T) {
+	mapper := newTestMapper()
+
+	cases := map[string]string{
+		"/auth/login":        "/api/v1/auth/login",
+		"/users/profile":     "/api/v1/users/me",
+		"/users/profile/x":   "/api/v1/users/profile/x",
+		"/users":             "/api/v1/users",
+		"/users/alice":       "/api/v1/users/alice",
+		"/users/me/2fa/next": "/api/v1/users/me/2fa/next",
+	}
+	for legacy, current := range cases {
+		rewritten, ok := mapper.Rewrite(legacy)
+		assert.True(t, ok, legacy)
+		assert.Equal(t, current, rewritten, legacy)
+	}
+
+	for _, path := range []string{"/api/v1/users/alice", "/media/avatars/1.jpg", "/healthz", "/usersx"} {
+		_, ok := mapper.Rewrite(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestVersioningMiddleware(t *testing.T) {
+	var seen string
+	handler := newTestMapper().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.URL.Path
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users/profile", nil))
+	assert.Equal(t, "/api/v1/users/me", seen)
+	assert.Equal(t, "@1792281600", rr.Header().Get("Deprecation"))
+	assert.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", rr.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/users/me>; rel="successor-version"`, rr.Header().Get("Link"))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/users/profile", nil))
+	assert.Equal(t, "/api/v1/users/profile", seen, "Current paths are left alone, so a user named profile can be viewed")
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+}
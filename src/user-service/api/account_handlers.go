@@ -0,0 +1,116 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"social-network/user-service/models"
+)
+
+func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.userService.DeleteAccount(r.Context(), username, req.Password)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid credentials") {
+			http.Error(w, "Invalid password", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Error deleting account: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	clearSessionCookies(w)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *UserHandler) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		http.Error(w, "format must be one of: json, zip", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.userService.ExportUserData(r.Context(), username)
+	if err != nil {
+		http.Error(w, "Error exporting user data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-export-%s", username, export.ExportedAt.Format("20060102T150405Z"))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(export)
+		return
+	}
+
+	// The archive is built in memory so that a failure can still be
+	// reported with a proper status instead of a truncated download.
+	var archive bytes.Buffer
+	if err := writeExportArchive(&archive, export); err != nil {
+		http.Error(w, "Error writing export archive: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(archive.Len()))
+	archive.WriteTo(w)
+}
+
+func writeExportArchive(w io.Writer, export *models.UserDataExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"export.json", export},
+		{"profile.json", export.Profile},
+		{"sessions.json", export.Sessions},
+		{"two_factor.json", export.TwoFactor},
+		{"audit_log.json", export.AuditLog},
+	}
+
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"social-network/user-service/models"
+	"social-network/user-service/service"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type AdminHandler struct {
+	adminService    *service.AdminService
+	effectiveConfig map[string]interface{}
+}
+
+// NewAdminHandler takes the effective configuration with secrets already
+// redacted; it is served as is.
+func NewAdminHandler(adminService *service.AdminService, effectiveConfig map[string]interface{}) *AdminHandler {
+	return &AdminHandler{
+		adminService:    adminService,
+		effectiveConfig: effectiveConfig,
+	}
+}
+
+// RegisterRoutes mounts the admin API behind auth's session and permission
+// middlewares.
+func (h *AdminHandler) RegisterRoutes(router *mux.Router, auth *UserHandler) {
+	protect := func(permission models.Permission, next http.HandlerFunc) http.HandlerFunc {
+		return auth.SessionAuthMiddleware(auth.RequirePermission(permission, next))
+	}
+
+	router.HandleFunc("/admin/users", protect(models.PermissionSearchUsers, h.SearchUsers)).Methods("GET")
+	router.HandleFunc("/admin/users/{username}/suspend", protect(models.PermissionSuspendUsers, h.SuspendUser)).Methods("POST")
+	router.HandleFunc("/admin/users/{username}/unsuspend", protect(models.PermissionSuspendUsers, h.UnsuspendUser)).Methods("POST")
+	router.HandleFunc("/admin/users/{username}/reset-password", protect(models.PermissionResetPasswords, h.ForcePasswordReset)).Methods("POST")
+	router.HandleFunc("/admin/users/{username}/role", protect(models.PermissionManageRoles, h.SetRole)).Methods("PUT")
+	router.HandleFunc("/admin/users/{username}/audit", protect(models.PermissionViewAudit, h.GetAuditHistory)).Methods("GET")
+	router.HandleFunc("/admin/config", protect(models.PermissionViewConfig, h.GetConfig)).Methods("GET")
+}
+
+func (h *AdminHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.effectiveConfig)
+}
+
+func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, err := h.adminService.SearchUsers(r.Context(), r.URL.Query().Get("query"), limit, offset)
+	if err != nil {
+		http.Error(w, "Error searching users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value("username").(string)
+	target := mux.Vars(r)["username"]
+
+	var req models.SuspendUserRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.adminService.SuspendUser(r.Context(), actor, target, req.Reason); err != nil {
+		writeAdminError(w, "Error suspending user: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User suspended"})
+}
+
+func (h *AdminHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value("username").(string)
+	target := mux.Vars(r)["username"]
+
+	if err := h.adminService.UnsuspendUser(r.Context(), actor, target); err != nil {
+		writeAdminError(w, "Error unsuspending user: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User unsuspended"})
+}
+
+func (h *AdminHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value("username").(string)
+	target := mux.Vars(r)["username"]
+
+	if err := h.adminService.ForcePasswordReset(r.Context(), actor, target); err != nil {
+		writeAdminError(w, "Error forcing password reset: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset required on next login"})
+}
+
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value("username").(string)
+	target := mux.Vars(r)["username"]
+
+	var req models.SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	role, err := models.ParseRole(req.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.adminService.SetRole(r.Context(), actor, target, role); err != nil {
+		writeAdminError(w, "Error changing role: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role updated"})
+}
+
+func (h *AdminHandler) GetAuditHistory(w http.ResponseWriter, r *http.Request) {
+	target := mux.Vars(r)["username"]
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.adminService.GetAuditHistory(r.Context(), target, limit, offset)
+	if err != nil {
+		writeAdminError(w, "Error retrieving audit history: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func writeAdminError(w http.ResponseWriter, prefix string, err error) {
+	if strings.Contains(err.Error(), "forbidden") {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if strings.Contains(err.Error(), "not found") {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+}
+
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+	}
+
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"mime"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"social-network/logging"
+	"social-network/metrics"
 	"social-network/user-service/models"
 	"social-network/user-service/service"
 )
@@ -26,9 +29,19 @@ func (h *UserHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/auth/signin", h.SignIn).Methods("POST")
 	router.HandleFunc("/auth/login", h.LoginWithSession).Methods("POST")
 	router.HandleFunc("/auth/logout", h.Logout).Methods("POST")
-	router.HandleFunc("/users/profile", h.SessionAuthMiddleware(h.GetUserProfileBySession)).Methods("GET")
-	router.HandleFunc("/users/update", h.SessionAuthMiddleware(h.UpdateUserProfileBySession)).Methods("PUT")
+	router.HandleFunc("/auth/2fa/verify", h.VerifyTwoFactorLogin).Methods("POST")
+	router.HandleFunc("/users/2fa/enroll", h.SessionAuthMiddleware(h.EnrollTwoFactor)).Methods("POST")
+	router.HandleFunc("/users/2fa/confirm", h.SessionAuthMiddleware(h.ConfirmTwoFactor)).Methods("POST")
+	router.HandleFunc("/users/2fa/disable", h.SessionAuthMiddleware(h.DisableTwoFactor)).Methods("POST")
+	router.HandleFunc("/users/me", h.SessionAuthMiddleware(h.GetUserProfileBySession)).Methods("GET")
+	router.HandleFunc("/users/me", h.SessionAuthMiddleware(h.UpdateUserProfileBySession)).Methods("PUT")
+	router.HandleFunc("/users/me", h.SessionAuthMiddleware(h.PatchUserProfile)).Methods("PATCH")
+	router.HandleFunc("/users/me", h.SessionAuthMiddleware(h.DeleteAccount)).Methods("DELETE")
+	router.HandleFunc("/users/me/export", h.SessionAuthMiddleware(h.ExportUserData)).Methods("GET")
+	router.HandleFunc("/users/password", h.PasswordResetMiddleware(h.ChangePassword)).Methods("PUT")
+	router.HandleFunc("/users/batch", h.BatchLookupUsers).Methods("POST")
 	router.HandleFunc("/users/{username}", h.GetUserPublicProfile).Methods("GET")
+	router.HandleFunc("/users", h.SearchUsers).Methods("GET")
 }
 
 func (h *UserHandler) SignIn(w http.ResponseWriter, r *http.Request) {
@@ -44,7 +57,7 @@ func (h *UserHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := h.userService.SignIn(&req)
+	response, err := h.userService.SignIn(r.Context(), &req)
 	if err != nil {
 		if strings.Contains(err.Error(), "already exists") {
 			http.Error(w, err.Error(), http.StatusConflict)
@@ -72,7 +85,7 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := h.userService.Login(&req)
+	response, err := h.userService.Login(r.Context(), &req)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid credentials") {
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
@@ -94,7 +107,7 @@ func (h *UserHandler) GetUserPublicProfile(w http.ResponseWriter, r *http.Reques
 	vars := mux.Vars(r)
 	username := vars["username"]
 
-	profile, err := h.userService.GetUserPublicProfile(username)
+	profile, err := h.userService.GetUserPublicProfile(r.Context(), username)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "User not found", http.StatusNotFound)
@@ -108,6 +121,80 @@ func (h *UserHandler) GetUserPublicProfile(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(profile)
 }
 
+func (h *UserHandler) BatchLookupUsers(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchUserLookupRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.userService.BatchLookupUsers(r.Context(), &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "at least one") || strings.Contains(err.Error(), "at most") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error looking up users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	if len(query) > 100 {
+		http.Error(w, "query must not be longer than 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	limit, _, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cursor, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.userService.SearchUsers(r.Context(), query, cursor, limit)
+	if err != nil {
+		http.Error(w, "Error searching users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.NextCursor = encodeCursor(response.NextCursor)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Cursors are opaque to clients so the pagination key can change later
+// without breaking them.
+func encodeCursor(value string) string {
+	if value == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	value, err := base64.RawURLEncoding.DecodeString(cursor)
+	return string(value), err
+}
+
 func extractBasicAuth(r *http.Request) (username, password string, ok bool) {
 	auth := r.Header.Get("Authorization")
 	if auth == "" {
@@ -147,13 +234,20 @@ func (h *UserHandler) LoginWithSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := h.userService.LoginWithSession(&req)
+	response, err := h.userService.LoginWithSession(r.Context(), &req)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid credentials") {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 			return
 		}
+		if strings.Contains(err.Error(), "suspended") {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+			http.Error(w, "Account suspended", http.StatusForbidden)
+			return
+		}
 		if strings.Contains(err.Error(), "not found") {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
 			http.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
@@ -161,21 +255,47 @@ func (h *UserHandler) LoginWithSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if response.TwoFactorRequired {
+		metrics.LoginAttempts.WithLabelValues(metrics.LoginTwoFactorRequired).Inc()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	metrics.LoginAttempts.WithLabelValues(metrics.LoginSuccess).Inc()
+	h.setSessionCookies(w, response)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// setSessionCookies sets the session cookie and a CSRF cookie readable by
+// scripts, which must echo it in the X-CSRF-Token header. The CSRF token is
+// also returned in the response body.
+func (h *UserHandler) setSessionCookies(w http.ResponseWriter, response *models.AuthResponse) {
+	expires := time.Now().Add(h.userService.SessionTTL())
+	response.CSRFToken = CSRFToken(response.Token)
+
 	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
+		Name:     sessionCookieName,
 		Value:    response.Token,
-		Expires:  time.Now().Add(24 * time.Hour),
+		Expires:  expires,
 		HttpOnly: true,
 		Path:     "/",
 		SameSite: http.SameSiteStrictMode,
 	})
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    response.CSRFToken,
+		Expires:  expires,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+	})
 }
 
 func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_token")
+	token, _, err := sessionToken(r)
 	if err != nil {
 		if err == http.ErrNoCookie {
 			w.WriteHeader(http.StatusOK)
@@ -186,27 +306,31 @@ func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.userService.Logout(cookie.Value)
+	err = h.userService.Logout(r.Context(), token)
 	if err != nil {
 		http.Error(w, "Logout failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Path:     "/",
-	})
+	clearSessionCookies(w)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"})
 }
 
 func (h *UserHandler) SessionAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return h.sessionAuth(next, false)
+}
+
+// PasswordResetMiddleware is SessionAuthMiddleware for the one endpoint a
+// user may still call while an admin-forced password reset is pending.
+func (h *UserHandler) PasswordResetMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return h.sessionAuth(next, true)
+}
+
+func (h *UserHandler) sessionAuth(next http.HandlerFunc, allowPendingReset bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_token")
+		token, _, err := sessionToken(r)
 		if err != nil {
 			if err == http.ErrNoCookie {
 				http.Error(w, "Unauthorized: No session token", http.StatusUnauthorized)
@@ -216,27 +340,134 @@ func (h *UserHandler) SessionAuthMiddleware(next http.HandlerFunc) http.HandlerF
 			return
 		}
 
-		session, err := h.userService.ValidateSession(cookie.Value)
+		session, err := h.userService.ValidateSession(r.Context(), token)
 		if err != nil {
 			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
 			return
 		}
 
+		user, err := h.userService.GetUserByUsername(r.Context(), session.Username)
+		if err != nil {
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if user.SuspendedAt != nil {
+			http.Error(w, "Forbidden: account suspended", http.StatusForbidden)
+			return
+		}
+		if user.PasswordResetRequired && !allowPendingReset {
+			http.Error(w, "Forbidden: password reset required", http.StatusForbidden)
+			return
+		}
+
+		logging.AddAttrs(r.Context(), "user_id", user.PublicID)
+
 		ctx := context.WithValue(r.Context(), "username", session.Username)
+		ctx = context.WithValue(ctx, "role", user.Role)
+		ctx = context.WithValue(ctx, "session_token", token)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// RequirePermission must be wrapped by SessionAuthMiddleware, which puts
+// the caller's role into the request context.
+func (h *UserHandler) RequirePermission(permission models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value("role").(models.Role)
+		if !role.Can(permission) {
+			http.Error(w, "Forbidden: missing permission "+string(permission), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+	token := r.Context().Value("session_token").(string)
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Username = username
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.userService.ChangePassword(r.Context(), username, token, &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid credentials") {
+			http.Error(w, "Invalid current password", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Error changing password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed successfully"})
+}
+
 func (h *UserHandler) GetUserProfileBySession(w http.ResponseWriter, r *http.Request) {
 	username := r.Context().Value("username").(string)
 
-	user, err := h.userService.GetUserByUsername(username)
+	user, err := h.userService.GetUserByUsername(r.Context(), username)
 	if err != nil {
 		http.Error(w, "Error retrieving user profile: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", user.ETag())
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) PatchUserProfile(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/merge-patch+json" && contentType != "application/json" {
+		http.Error(w, "Content-Type must be application/merge-patch+json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req models.UserPatchRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "Invalid merge patch document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.PatchUserProfile(r.Context(), username, &req, r.Header.Get("If-Match"))
+	if err != nil {
+		if strings.Contains(err.Error(), "precondition failed") {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		if strings.Contains(err.Error(), "already used") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "cannot be cleared") || strings.Contains(err.Error(), "invalid phone number") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error updating profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", user.ETag())
 	json.NewEncoder(w).Encode(user)
 }
 
@@ -245,7 +476,7 @@ func (h *UserHandler) UpdateUserProfileBySession(w http.ResponseWriter, r *http.
 
 	var req models.UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -254,8 +485,16 @@ func (h *UserHandler) UpdateUserProfileBySession(w http.ResponseWriter, r *http.
 		return
 	}
 
-	updatedUser, err := h.userService.UpdateUserProfileBySession(username, &req)
+	updatedUser, err := h.userService.UpdateUserProfileBySession(r.Context(), username, &req)
 	if err != nil {
+		if strings.Contains(err.Error(), "invalid phone number") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(err.Error(), "already used") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "Error updating profile: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"social-network/user-service/media"
+	"social-network/user-service/service"
+)
+
+type MediaHandler struct {
+	avatarService *service.AvatarService
+	store         media.BlobStore
+}
+
+func NewMediaHandler(avatarService *service.AvatarService, store media.BlobStore) *MediaHandler {
+	return &MediaHandler{
+		avatarService: avatarService,
+		store:         store,
+	}
+}
+
+func (h *MediaHandler) RegisterRoutes(router *mux.Router, auth *UserHandler) {
+	router.HandleFunc("/users/me/avatar", auth.SessionAuthMiddleware(h.UploadAvatar)).Methods("PUT")
+	router.HandleFunc("/users/me/avatar", auth.SessionAuthMiddleware(h.DeleteAvatar)).Methods("DELETE")
+}
+
+// RegisterFileRoutes serves the stored files. They stay outside the
+// versioned API because their URLs are saved in user profiles.
+func (h *MediaHandler) RegisterFileRoutes(router *mux.Router) {
+	router.HandleFunc("/media/{key:.+}", h.ServeMedia).Methods("GET")
+}
+
+func (h *MediaHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	// Leave some room for the multipart envelope on top of the image itself.
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxAvatarBytes+64<<10)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Avatar must not exceed 5 MB", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Multipart form with an \"avatar\" file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxAvatarBytes+1))
+	if err != nil {
+		http.Error(w, "Error reading upload", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.avatarService.SetAvatar(r.Context(), username, data)
+	if err != nil {
+		if strings.Contains(err.Error(), "too large") {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if strings.Contains(err.Error(), "unsupported image type") {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid image") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error saving avatar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"avatar_url":           user.AvatarURL,
+		"avatar_thumbnail_url": user.AvatarThumbnailURL,
+	})
+}
+
+func (h *MediaHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	if err := h.avatarService.DeleteAvatar(r.Context(), username); err != nil {
+		http.Error(w, "Error deleting avatar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Avatar deleted"})
+}
+
+func (h *MediaHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	blob, err := h.store.Open(key)
+	if err != nil {
+		if errors.Is(err, media.ErrBlobNotFound) || strings.Contains(err.Error(), "invalid blob key") {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Error reading media", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	io.Copy(w, blob)
+}
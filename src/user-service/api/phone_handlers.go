@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"social-network/user-service/models"
+	"social-network/user-service/service"
+)
+
+type PhoneHandler struct {
+	phoneService *service.PhoneService
+}
+
+func NewPhoneHandler(phoneService *service.PhoneService) *PhoneHandler {
+	return &PhoneHandler{phoneService: phoneService}
+}
+
+func (h *PhoneHandler) RegisterRoutes(router *mux.Router, auth *UserHandler) {
+	router.HandleFunc("/users/me/phone/verification", auth.SessionAuthMiddleware(h.StartVerification)).Methods("POST")
+	router.HandleFunc("/users/me/phone/verification/confirm", auth.SessionAuthMiddleware(h.ConfirmVerification)).Methods("POST")
+}
+
+func (h *PhoneHandler) StartVerification(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	response, err := h.phoneService.StartVerification(r.Context(), username)
+	if err != nil {
+		writePhoneError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *PhoneHandler) ConfirmVerification(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	var req models.PhoneConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.phoneService.ConfirmVerification(r.Context(), username, req.Code)
+	if err != nil {
+		writePhoneError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", user.ETag())
+	json.NewEncoder(w).Encode(user)
+}
+
+func writePhoneError(w http.ResponseWriter, err error) {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "too many"):
+		http.Error(w, message, http.StatusTooManyRequests)
+	case strings.Contains(message, "already"):
+		http.Error(w, message, http.StatusConflict)
+	case strings.Contains(message, "no phone number"),
+		strings.Contains(message, "no pending"),
+		strings.Contains(message, "invalid verification code"),
+		strings.Contains(message, "expired"),
+		strings.Contains(message, "changed since"):
+		http.Error(w, message, http.StatusBadRequest)
+	default:
+		http.Error(w, "Error verifying phone number: "+message, http.StatusInternalServerError)
+	}
+}
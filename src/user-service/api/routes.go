@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/gorilla/mux"
+)
+
+// PathPrefix is where the current version of the API is mounted. The
+// gateway maps the old unversioned paths onto it.
+const PathPrefix = "/api/v1"
+
+// RegisterAPI mounts every handler on router: media files at the root and
+// the API under PathPrefix. The contract tests build the same router.
+func RegisterAPI(router *mux.Router, users *UserHandler, admin *AdminHandler, media *MediaHandler, phone *PhoneHandler) {
+	media.RegisterFileRoutes(router)
+
+	v1 := router.PathPrefix(PathPrefix).Subrouter()
+	media.RegisterRoutes(v1, users)
+	users.RegisterRoutes(v1)
+	admin.RegisterRoutes(v1, users)
+	phone.RegisterRoutes(v1, users)
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"social-network/metrics"
+	"social-network/user-service/models"
+)
+
+func (h *UserHandler) VerifyTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorLoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.userService.VerifyTwoFactorLogin(r.Context(), &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid two-factor code") {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+			http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(err.Error(), "challenge") {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+			http.Error(w, "Login challenge expired or not found", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Login failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metrics.LoginAttempts.WithLabelValues(metrics.LoginSuccess).Inc()
+	h.setSessionCookies(w, response)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *UserHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	response, err := h.userService.EnrollTwoFactor(r.Context(), username)
+	if err != nil {
+		if strings.Contains(err.Error(), "already enabled") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error enrolling two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *UserHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.userService.ConfirmTwoFactor(r.Context(), username, req.Code)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid two-factor code") {
+			http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(err.Error(), "not enrolled") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(err.Error(), "already enabled") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error confirming two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := Validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.userService.DisableTwoFactor(r.Context(), username, req.Code)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid two-factor code") {
+			http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(err.Error(), "not enabled") || strings.Contains(err.Error(), "not enrolled") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error disabling two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
@@ -3,13 +3,26 @@ package api
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+
+	"social-network/user-service/models"
+	"social-network/user-service/password"
 )
 
 var validate *validator.Validate
 
+var passwordPolicy = password.DefaultPolicy
+
+// SetPasswordPolicy replaces the policy enforced by the password_policy tag.
+// It is meant to be called once at startup.
+func SetPasswordPolicy(policy password.Policy) {
+	passwordPolicy = policy
+}
+
 func init() {
 	validate = validator.New()
 
@@ -20,6 +33,92 @@ func init() {
 		}
 		return name
 	})
+
+	// Dates are validated as their string form; absent and null dates
+	// become nil so omitempty skips them.
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		var date models.NullDate
+		switch value := field.Interface().(type) {
+		case models.OptionalDate:
+			date = value.NullDate
+		case models.NullDate:
+			date = value
+		}
+		if !date.Valid {
+			return nil
+		}
+		return date.Date.String()
+	}, models.OptionalDate{}, models.NullDate{})
+
+	// Merge patch fields are validated by their value; absent and null
+	// fields become nil so omitempty skips them.
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		switch value := field.Interface().(type) {
+		case models.Optional[string]:
+			if value.Set && !value.Null {
+				return value.Value
+			}
+		case models.Optional[[]string]:
+			if value.Set && !value.Null {
+				return value.Value
+			}
+		}
+		return nil
+	}, models.Optional[string]{}, models.Optional[[]string]{})
+
+	validate.RegisterValidation("age_range", validateAgeRange)
+	validate.RegisterValidation("password_policy", validatePasswordPolicy)
+	validate.RegisterValidation("not_reserved", func(fl validator.FieldLevel) bool {
+		return !models.IsReservedUsername(fl.Field().String())
+	})
+}
+
+// validatePasswordPolicy also checks the password against the username and
+// email of the struct it belongs to.
+func validatePasswordPolicy(fl validator.FieldLevel) bool {
+	return len(passwordPolicy.Check(fl.Field().String(), personalInfo(fl.Parent())...)) == 0
+}
+
+func personalInfo(parent reflect.Value) []string {
+	for parent.Kind() == reflect.Ptr {
+		parent = parent.Elem()
+	}
+	if parent.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var values []string
+	for _, name := range []string{"Username", "Email"} {
+		field := parent.FieldByName(name)
+		if field.IsValid() && field.Kind() == reflect.String {
+			values = append(values, field.String())
+		}
+	}
+	return values
+}
+
+// validateAgeRange checks a YYYY-MM-DD date against "min:max" years of age.
+func validateAgeRange(fl validator.FieldLevel) bool {
+	bounds := strings.SplitN(fl.Param(), ":", 2)
+	if len(bounds) != 2 {
+		return false
+	}
+	minAge, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return false
+	}
+	maxAge, err := strconv.Atoi(bounds[1])
+	if err != nil {
+		return false
+	}
+
+	date, err := models.ParseDate(fl.Field().String())
+	if err != nil {
+		return false
+	}
+
+	age := date.AgeAt(time.Now())
+	return age >= minAge && age <= maxAge
 }
 
 func Validate(s interface{}) error {
@@ -41,6 +140,21 @@ func Validate(s interface{}) error {
 			errorMessages = append(errorMessages, fmt.Sprintf("%s must be min %s characters long", e.Field(), e.Param()))
 		case "max":
 			errorMessages = append(errorMessages, fmt.Sprintf("%s must not max %s characters long", e.Field(), e.Param()))
+		case "http_url":
+			errorMessages = append(errorMessages, fmt.Sprintf("%s must be a valid http or https URL", e.Field()))
+		case "uuid":
+			errorMessages = append(errorMessages, fmt.Sprintf("%s must be a valid UUID", e.Field()))
+		case "oneof":
+			errorMessages = append(errorMessages, fmt.Sprintf("%s must be one of: %s", e.Field(), e.Param()))
+		case "age_range":
+			errorMessages = append(errorMessages, fmt.Sprintf("%s must correspond to an age between %s years", e.Field(), strings.Replace(e.Param(), ":", " and ", 1)))
+		case "password_policy":
+			// Report every broken rule, not just that the policy failed.
+			for _, violation := range passwordPolicy.Check(fmt.Sprint(e.Value()), personalInfo(reflect.ValueOf(s))...) {
+				errorMessages = append(errorMessages, fmt.Sprintf("%s %s", e.Field(), violation.Message))
+			}
+		case "not_reserved":
+			errorMessages = append(errorMessages, fmt.Sprintf("%s %q is reserved", e.Field(), e.Value()))
 		case "datetime":
 			errorMessages = append(errorMessages, fmt.Sprintf("%s must be a valid date in format %s", e.Field(), e.Param()))
 		default:
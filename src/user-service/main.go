@@ -3,153 +3,335 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel"
 
+	"social-network/config"
+	"social-network/health"
+	"social-network/logging"
+	"social-network/metrics"
+	"social-network/openapi"
+	"social-network/tracing"
 	"social-network/user-service/api"
+	"social-network/user-service/media"
+	"social-network/user-service/models"
 	"social-network/user-service/repository"
 	"social-network/user-service/service"
+	"social-network/user-service/setup"
+	"social-network/user-service/sms"
 )
 
+const healthCheckTimeout = 2 * time.Second
+
 func main() {
-	dbHost := getEnv("DB_HOST", "postgres")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbUser := getEnv("DB_USER", "postgres")
-	dbPassword := getEnv("DB_PASSWORD", "postgres")
-	dbName := getEnv("DB_NAME", "socialnetwork")
-	serverPort := getEnv("SERVER_PORT", "8000")
-
-	dbConnectionString := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName,
-	)
-
-	var db *sql.DB
-	var err error
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		log.Printf("Attempting to connect to database (attempt %d/%d)...", i+1, maxRetries)
-		db, err = sql.Open("postgres", dbConnectionString)
-		if err == nil {
-			err = db.Ping()
-			if err == nil {
-				log.Println("Successfully connected to the database!")
-				break
-			}
-		}
+	cfg, err := config.LoadUserService(os.Args[1:])
+	if err == nil {
+		err = setup.Check(cfg)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
-		log.Printf("Failed to connect to database: %v", err)
-		if i < maxRetries-1 {
-			retryDelay := time.Duration(2<<uint(i)) * time.Second
-			log.Printf("Retrying in %v...", retryDelay)
-			time.Sleep(retryDelay)
-		} else {
-			log.Fatalf("Could not connect to the database after %d attempts", maxRetries)
-		}
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	// Route the standard log package through slog as well, so that any
+	// remaining log.Printf calls end up as JSON lines too.
+	slog.SetDefault(logger)
+
+	if effective, err := json.Marshal(config.Redact(cfg)); err == nil {
+		slog.Info("effective configuration", "config", json.RawMessage(effective))
 	}
 
-	db.SetMaxOpenConns(20)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	shutdownTracing, err := tracing.Setup(context.Background(), "user-service", cfg.Tracing.Options())
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+
+	hasher, err := setup.PasswordHasher(cfg.Password)
+	if err != nil {
+		fatal("invalid password configuration", err)
+	}
+
+	passwordPolicy, err := setup.PasswordPolicy(cfg.Password)
+	if err != nil {
+		fatal("invalid password configuration", err)
+	}
+	if passwordPolicy.Breached != nil {
+		slog.Info("loaded breached password list", "hashes", passwordPolicy.Breached.Len())
+	}
+	api.SetPasswordPolicy(passwordPolicy)
+
+	smsSender, err := setup.SMSSender(cfg.Phone)
+	if err != nil {
+		fatal("invalid SMS configuration", err)
+	}
+	if cfg.Phone.SMSProvider == sms.ProviderLog {
+		slog.Warn("SMS messages, one-time codes included, are written to the log instead of being delivered; use the log provider in development only")
+	}
+
+	db, err := openDatabase(cfg.Database, cfg.Database.DSN())
+	if err != nil {
+		fatal("invalid database configuration", err)
+	}
+	databases := []*sql.DB{db}
+	metrics.RegisterDB(db, cfg.Database.Name)
 
 	userRepo := repository.NewUserRepository(db)
 	sessionRepo := repository.NewSessionRepository(db)
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	phoneVerificationRepo := repository.NewPhoneVerificationRepository(db)
+	metrics.RegisterActiveSessions(sessionRepo.CountActiveSessions)
 
-	if err := userRepo.Init(); err != nil {
-		log.Fatalf("Failed to initialize user repository: %v", err)
+	if replicaDSN := cfg.Database.ReplicaDSN(); replicaDSN != "" {
+		replica, err := openDatabase(cfg.Database, replicaDSN)
+		if err != nil {
+			fatal("invalid read replica configuration", err)
+		}
+		databases = append(databases, replica)
+		metrics.RegisterDB(replica, cfg.Database.Name+"_replica")
+		userRepo.SetReadReplica(replica)
 	}
 
-	if err := sessionRepo.Init(); err != nil {
-		log.Fatalf("Failed to initialize session repository: %v", err)
+	var migrated atomic.Bool
+	migrate := func() error {
+		initializers := []struct {
+			name string
+			init func() error
+		}{
+			{"user", userRepo.Init},
+			{"session", sessionRepo.Init},
+			{"two-factor", twoFactorRepo.Init},
+			{"audit", auditRepo.Init},
+			{"phone verification", phoneVerificationRepo.Init},
+		}
+		for _, initializer := range initializers {
+			if err := initializer.init(); err != nil {
+				return fmt.Errorf("failed to initialize %s repository: %w", initializer.name, err)
+			}
+		}
+
+		if bootstrapAdmin := cfg.BootstrapAdmin; bootstrapAdmin != "" {
+			if err := userRepo.SetRole(context.Background(), bootstrapAdmin, models.RoleAdmin); err != nil {
+				slog.Error("failed to grant admin role", "username", bootstrapAdmin, "error", err)
+			} else {
+				slog.Info("granted admin role", "username", bootstrapAdmin)
+			}
+		}
+
+		migrated.Store(true)
+		return nil
 	}
 
-	userService := service.NewUserService(userRepo, sessionRepo)
+	stopBackground := make(chan struct{})
+	databaseReady := make(chan struct{})
+	databaseDegraded := make(chan struct{})
+	go prepareDatabase(migrate, cfg.Database, databaseReady, databaseDegraded, stopBackground)
+	select {
+	case <-databaseReady:
+	case <-databaseDegraded:
+		slog.Warn("database unavailable, starting in degraded mode")
+	}
+
+	blobStore, err := media.NewLocalBlobStore(cfg.Media.Root, cfg.Media.BaseURL)
+	if err != nil {
+		fatal("failed to initialize media storage", err)
+	}
+
+	phoneService := service.NewPhoneService(userRepo, phoneVerificationRepo, smsSender, service.PhoneOptions{
+		DefaultRegion: cfg.Phone.DefaultRegion,
+		RequireUnique: cfg.Phone.RequireUnique,
+	})
+	userService := service.NewUserService(userRepo, sessionRepo, twoFactorRepo, auditRepo, phoneService, hasher, cfg.Session.TTL)
+	adminService := service.NewAdminService(db, userRepo, sessionRepo, auditRepo)
+	avatarService := service.NewAvatarService(userRepo, blobStore)
 	userHandler := api.NewUserHandler(userService)
+	adminHandler := api.NewAdminHandler(adminService, config.Redact(cfg))
+	mediaHandler := api.NewMediaHandler(avatarService, blobStore)
+	phoneHandler := api.NewPhoneHandler(phoneService)
+	checker := health.NewChecker(healthCheckTimeout)
+	checker.Add("database", db.PingContext)
+	checker.Add("migrations", func(ctx context.Context) error {
+		if !migrated.Load() {
+			return errors.New("schema migrations have not been applied yet")
+		}
+		return nil
+	})
+	checker.Add("schema", func(ctx context.Context) error {
+		return repository.CheckSchema(ctx, db)
+	})
+
+	// The API port only tells whether the service is ready; metrics and the
+	// report with database errors are served on the internal port.
 	router := mux.NewRouter()
-	userHandler.RegisterRoutes(router)
-	router.Use(loggingMiddleware)
+	router.HandleFunc("/healthz", checker.LivenessHandler).Methods("GET")
+	router.HandleFunc("/readyz", checker.StatusHandler).Methods("GET")
+	api.RegisterAPI(router, userHandler, adminHandler, mediaHandler, phoneHandler)
+	router.Use(logging.Middleware(logger))
+	router.Use(tracing.Middleware)
+	router.Use(metrics.Middleware)
 	router.Use(recoveryMiddleware)
+	router.Use(api.CSRFMiddleware)
+	if cfg.OpenAPI.Validate {
+		validator, err := openapi.Load(cfg.OpenAPI.SpecFile)
+		if err != nil {
+			fatal("failed to load the OpenAPI spec", err)
+		}
+		router.Use(validator.Middleware)
+		slog.Warn("validating requests and responses against the OpenAPI spec", "spec", cfg.OpenAPI.SpecFile)
+	}
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%s", serverPort),
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:      tracing.Handler(router, "user-service"),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
-	stopCleanup := make(chan struct{})
-	go periodicSessionCleanup(sessionRepo, stopCleanup)
+	internalRouter := mux.NewRouter()
+	internalRouter.Handle("/metrics", metrics.Handler()).Methods("GET")
+	internalRouter.HandleFunc("/healthz", checker.LivenessHandler).Methods("GET")
+	internalRouter.HandleFunc("/readyz", checker.ReadinessHandler).Methods("GET")
+	internalServer := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Server.InternalPort),
+		Handler:      internalRouter,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	go periodicSessionCleanup(cfg.Session.CleanupInterval, sessionRepo, twoFactorRepo, userService, avatarService, phoneService, stopBackground)
 
 	go func() {
-		log.Printf("User service starting on port %s...", serverPort)
+		slog.Info("user service starting", "port", cfg.Server.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("failed to start server", err)
 		}
 	}()
 
-	shutdownGracefully(server, stopCleanup, db)
-}
+	go func() {
+		slog.Info("internal listener starting", "addr", internalServer.Addr)
+		if err := internalServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("failed to start internal listener", err)
+		}
+	}()
 
-func periodicSessionCleanup(sessionRepo *repository.SessionRepository, stop <-chan struct{}) {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
+	shutdownGracefully(server, internalServer, checker, cfg.Server, stopBackground, databases, shutdownTracing)
+}
 
-	if err := sessionRepo.CleanExpiredSessions(); err != nil {
-		log.Printf("Error cleaning up expired sessions: %v", err)
+func openDatabase(dbConfig config.DatabaseConfig, dsn string) (*sql.DB, error) {
+	db, err := tracing.OpenDB("postgres", dsn)
+	if err != nil {
+		return nil, err
 	}
+	db.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	db.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	db.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(dbConfig.ConnMaxIdleTime)
+	return db, nil
+}
+
+// prepareDatabase applies the schema, retrying with exponential backoff
+// until it succeeds or stop is closed. ready is closed on success; degraded
+// is closed after connect_retries failed attempts so that startup can go on
+// without the database. database/sql reconnects by itself afterwards, so
+// this only has to get the schema in place once.
+func prepareDatabase(migrate func() error, dbConfig config.DatabaseConfig, ready, degraded chan<- struct{}, stop <-chan struct{}) {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err := migrate()
+		if err == nil {
+			slog.Info("database ready", "attempt", attempt)
+			close(ready)
+			return
+		}
+
+		slog.Warn("database not ready", "attempt", attempt, "error", err, "retry_in", delay)
+		if attempt == dbConfig.ConnectRetries {
+			close(degraded)
+		}
 
-	for {
 		select {
-		case <-ticker.C:
-			log.Println("Cleaning up expired sessions...")
-			if err := sessionRepo.CleanExpiredSessions(); err != nil {
-				log.Printf("Error cleaning up expired sessions: %v", err)
-			}
+		case <-time.After(delay):
 		case <-stop:
-			log.Println("Stopping session cleanup routine")
 			return
 		}
+		delay = min(delay*2, dbConfig.ReconnectMaxDelay)
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+func periodicSessionCleanup(
+	interval time.Duration,
+	sessionRepo *repository.SessionRepository,
+	twoFactorRepo *repository.TwoFactorRepository,
+	userService *service.UserService,
+	avatarService *service.AvatarService,
+	phoneService *service.PhoneService,
+	stop <-chan struct{},
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cleanup := func() {
+		ctx, span := otel.Tracer("social-network/user-service").Start(context.Background(), "periodic cleanup")
+		defer span.End()
+
+		if err := sessionRepo.CleanExpiredSessions(ctx); err != nil {
+			slog.Error("failed to clean up expired sessions", "error", err)
+		}
+		if err := twoFactorRepo.CleanExpiredChallenges(ctx); err != nil {
+			slog.Error("failed to clean up expired login challenges", "error", err)
+		}
+		if err := phoneService.CleanExpiredVerifications(ctx); err != nil {
+			slog.Error("failed to clean up expired phone verifications", "error", err)
+		}
+		if err := avatarService.PurgeDeletedAvatars(ctx); err != nil {
+			slog.Error("failed to purge avatars of deleted accounts", "error", err)
+		}
+		purged, err := userService.PurgeDeletedAccounts(ctx)
+		if err != nil {
+			slog.Error("failed to purge deleted accounts", "error", err)
+		} else if purged > 0 {
+			slog.Info("purged deleted accounts", "count", purged)
+		}
 	}
-	return value
-}
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		wrw := newResponseWriterWrapper(w)
-		next.ServeHTTP(wrw, r)
-		log.Printf(
-			"%s %s %d %s %s",
-			r.Method,
-			r.RequestURI,
-			wrw.statusCode,
-			r.RemoteAddr,
-			time.Since(start),
-		)
-	})
+	cleanup()
+
+	for {
+		select {
+		case <-ticker.C:
+			slog.Debug("running periodic cleanup")
+			cleanup()
+		case <-stop:
+			slog.Info("stopping periodic cleanup")
+			return
+		}
+	}
 }
 
 func recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
+				logging.FromContext(r.Context()).Error("panic recovered", "panic", fmt.Sprint(err), "stack", string(debug.Stack()))
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 			}
 		}()
@@ -157,41 +339,53 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-type responseWriterWrapper struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func newResponseWriterWrapper(w http.ResponseWriter) *responseWriterWrapper {
-	return &responseWriterWrapper{
-		ResponseWriter: w,
-		statusCode:     http.StatusOK,
-	}
-}
-
-func (rww *responseWriterWrapper) WriteHeader(statusCode int) {
-	rww.statusCode = statusCode
-	rww.ResponseWriter.WriteHeader(statusCode)
-}
-
-func shutdownGracefully(server *http.Server, stopCleanup chan<- struct{}, db *sql.DB) {
+func shutdownGracefully(
+	server *http.Server,
+	internalServer *http.Server,
+	checker *health.Checker,
+	serverConfig config.ServerConfig,
+	stopBackground chan<- struct{},
+	databases []*sql.DB,
+	shutdownTracing func(context.Context) error,
+) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
 	<-stop
-	log.Println("Shutting down gracefully...")
-	close(stopCleanup)
+	// Fail readiness first and keep serving for a while, so that the
+	// orchestrator takes the instance out of rotation before connections
+	// start getting refused.
+	checker.SetDraining()
+	slog.Info("draining", "delay", serverConfig.DrainDelay)
+	time.Sleep(serverConfig.DrainDelay)
+
+	slog.Info("shutting down gracefully")
+	close(stopBackground)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		slog.Error("server shutdown error", "error", err)
+	}
+	if err := internalServer.Shutdown(ctx); err != nil {
+		slog.Error("internal listener shutdown error", "error", err)
 	}
 
-	if err := db.Close(); err != nil {
-		log.Printf("Database closure error: %v", err)
+	for _, db := range databases {
+		if err := db.Close(); err != nil {
+			slog.Error("database closure error", "error", err)
+		}
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
 	}
 
-	log.Println("Server stopped gracefully")
+	slog.Info("server stopped gracefully")
+}
+
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
 }
@@ -0,0 +1,136 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	MaxAvatarBytes     = 5 << 20
+	MaxAvatarDimension = 4096
+
+	AvatarLargeSize     = 512
+	AvatarThumbnailSize = 128
+
+	avatarJPEGQuality = 85
+)
+
+var allowedAvatarTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type ProcessedAvatar struct {
+	Large     []byte
+	Thumbnail []byte
+}
+
+// ProcessAvatar sniffs the uploaded bytes instead of trusting the declared
+// content type, rejects oversized images before decoding them, and
+// re-encodes square JPEG versions, which also strips any embedded metadata.
+func ProcessAvatar(data []byte) (*ProcessedAvatar, error) {
+	if len(data) > MaxAvatarBytes {
+		return nil, errors.New("image too large")
+	}
+
+	if !allowedAvatarTypes[http.DetectContentType(data)] {
+		return nil, errors.New("unsupported image type")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+	if config.Width > MaxAvatarDimension || config.Height > MaxAvatarDimension {
+		return nil, errors.New("image dimensions too large")
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+
+	large, err := encodeJPEG(ResizeSquare(src, AvatarLargeSize))
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := encodeJPEG(ResizeSquare(src, AvatarThumbnailSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcessedAvatar{
+		Large:     large,
+		Thumbnail: thumbnail,
+	}, nil
+}
+
+// ResizeSquare center-crops src to a square and scales it to size x size
+// with a box filter. Images smaller than size are scaled up.
+func ResizeSquare(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	// Flatten onto white so transparent PNGs and GIFs survive JPEG encoding.
+	flat := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, image.Pt(x0, y0), draw.Over)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0 := y * side / size
+		sy1 := (y + 1) * side / size
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < size; x++ {
+			sx0 := x * side / size
+			sx1 := (x + 1) * side / size
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				offset := flat.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(flat.Pix[offset])
+					g += uint32(flat.Pix[offset+1])
+					b += uint32(flat.Pix[offset+2])
+					offset += 4
+					n++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = 0xff
+		}
+	}
+
+	return dst
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: avatarJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
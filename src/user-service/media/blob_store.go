@@ -0,0 +1,18 @@
+package media
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded media. Keys are slash-separated paths such as
+// "avatars/<user>/<version>-large.jpg"; URL turns a key into the address
+// clients should fetch it from.
+type BlobStore interface {
+	Put(key string, data io.Reader, contentType string) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	URL(key string) string
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the ISO 8601 calendar date format used on the wire.
+const DateLayout = "2006-01-02"
+
+// Date is a calendar date without time of day or time zone.
+type Date struct {
+	t time.Time
+}
+
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{t: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func DateOf(t time.Time) Date {
+	return NewDate(t.Date())
+}
+
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return Date{t: t}, nil
+}
+
+func (d Date) Time() time.Time {
+	return d.t
+}
+
+func (d Date) String() string {
+	return d.t.Format(DateLayout)
+}
+
+func (d Date) Equal(other Date) bool {
+	return d.t.Equal(other.t)
+}
+
+// AgeAt returns the number of full years between d and now.
+func (d Date) AgeAt(now time.Time) int {
+	year, month, day := now.Date()
+	age := year - d.t.Year()
+	if month < d.t.Month() || (month == d.t.Month() && day < d.t.Day()) {
+		age--
+	}
+	return age
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("date must be a string in YYYY-MM-DD format")
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// NullDate is a Date that may be NULL, in the spirit of sql.NullTime. It
+// serializes to JSON null instead of a zero date.
+type NullDate struct {
+	Date  Date
+	Valid bool
+}
+
+func NewNullDate(date Date) NullDate {
+	return NullDate{Date: date, Valid: true}
+}
+
+func (n NullDate) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return n.Date.MarshalJSON()
+}
+
+func (n *NullDate) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*n = NullDate{}
+		return nil
+	}
+	if err := n.Date.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+func (n *NullDate) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*n = NullDate{}
+		return nil
+	case time.Time:
+		*n = NewNullDate(DateOf(v))
+		return nil
+	case string:
+		return n.scanString(v)
+	case []byte:
+		return n.scanString(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into NullDate", value)
+	}
+}
+
+func (n *NullDate) scanString(value string) error {
+	if len(value) > len(DateLayout) {
+		value = value[:len(DateLayout)]
+	}
+	date, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*n = NewNullDate(date)
+	return nil
+}
+
+func (n NullDate) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Date.String(), nil
+}
+
+// OptionalDate distinguishes a field missing from a JSON document (Set is
+// false, leave unchanged) from an explicit null (Set is true, Valid is
+// false, clear the value).
+type OptionalDate struct {
+	NullDate
+	Set bool
+}
+
+func (o *OptionalDate) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return o.NullDate.UnmarshalJSON(data)
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// UserDataExport is everything the user-service stores about a user.
+// Secrets (password hash, session tokens, TOTP secret, recovery code
+// hashes) are deliberately left out, and so are the usernames of the staff
+// members behind audit log entries.
+type UserDataExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	Profile    ProfileExport    `json:"profile"`
+	Sessions   []SessionExport  `json:"sessions"`
+	TwoFactor  *TwoFactorExport `json:"two_factor"`
+	AuditLog   []AuditExport    `json:"audit_log"`
+}
+
+type ProfileExport struct {
+	PublicID              string     `json:"public_id"`
+	Username              string     `json:"username"`
+	Email                 string     `json:"email"`
+	Name                  string     `json:"name"`
+	Surname               string     `json:"surname"`
+	Birthdate             NullDate   `json:"birthdate"`
+	PhoneNumber           string     `json:"phone_number"`
+	PhoneVerifiedAt       *time.Time `json:"phone_verified_at"`
+	Bio                   string     `json:"bio"`
+	Links                 []string   `json:"links"`
+	AvatarURL             string     `json:"avatar_url"`
+	Role                  Role       `json:"role"`
+	SuspendedAt           *time.Time `json:"suspended_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	DeletedAt             *time.Time `json:"deleted_at"`
+}
+
+type SessionExport struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TwoFactorExport struct {
+	Enabled       bool                 `json:"enabled"`
+	EnrolledAt    time.Time            `json:"enrolled_at"`
+	RecoveryCodes []RecoveryCodeExport `json:"recovery_codes"`
+}
+
+type RecoveryCodeExport struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// AuditExport is an audit log entry about the user: what staff did to the
+// account and when.
+type AuditExport struct {
+	Action    string    `json:"action"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
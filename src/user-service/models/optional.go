@@ -0,0 +1,47 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Optional is a field of a JSON Merge Patch (RFC 7396) document. Absent
+// fields leave Set false, explicit nulls set Null, anything else is decoded
+// into Value.
+type Optional[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		var zero T
+		o.Value = zero
+		o.Null = true
+		return nil
+	}
+	o.Null = false
+	return json.Unmarshal(data, &o.Value)
+}
+
+// ETag is derived from updated_at, so any write to the row invalidates it.
+// Microseconds match the precision Postgres stores timestamps with.
+func (u *User) ETag() string {
+	return `"` + strconv.FormatInt(u.UpdatedAt.UnixMicro(), 36) + `"`
+}
+
+// ETagMatches evaluates an If-Match header value against the current
+// entity tag using strong comparison.
+func ETagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
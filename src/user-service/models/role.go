@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionSearchUsers    Permission = "users:search"
+	PermissionSuspendUsers   Permission = "users:suspend"
+	PermissionResetPasswords Permission = "users:reset_password"
+	PermissionManageRoles    Permission = "users:manage_roles"
+	PermissionViewAudit      Permission = "audit:view"
+	PermissionViewConfig     Permission = "config:view"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleUser: {},
+	RoleModerator: {
+		PermissionSearchUsers,
+		PermissionSuspendUsers,
+		PermissionViewAudit,
+	},
+	RoleAdmin: {
+		PermissionSearchUsers,
+		PermissionSuspendUsers,
+		PermissionResetPasswords,
+		PermissionManageRoles,
+		PermissionViewAudit,
+		PermissionViewConfig,
+	},
+}
+
+func ParseRole(value string) (Role, error) {
+	role := Role(value)
+	if _, ok := rolePermissions[role]; !ok {
+		return "", errors.New("unknown role")
+	}
+	return role, nil
+}
+
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type AuditEntry struct {
+	ID             int64     `json:"id"`
+	ActorUsername  string    `json:"actor"`
+	TargetUsername string    `json:"target"`
+	Action         string    `json:"action"`
+	Details        string    `json:"details,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AdminUserView is what moderators and admins see in search results; it
+// exposes account state but never credentials.
+type AdminUserView struct {
+	ID                    int64      `json:"id"`
+	Username              string     `json:"username"`
+	Email                 string     `json:"email"`
+	Name                  string     `json:"name"`
+	Surname               string     `json:"surname"`
+	Role                  Role       `json:"role"`
+	SuspendedAt           *time.Time `json:"suspended_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	DeletedAt             *time.Time `json:"deleted_at"`
+	CreatedAt             time.Time  `json:"created_at"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password_policy"`
+
+	// Username is filled in from the session so the password policy can
+	// reject new passwords containing it.
+	Username string `json:"-"`
+}
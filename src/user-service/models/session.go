@@ -14,10 +14,15 @@ type Session struct {
 }
 
 type AuthResponse struct {
-	Username  string `json:"username"`
-	Message   string `json:"message"`
-	Token     string `json:"token,omitempty"`
-	ExpiresAt string `json:"expires_at,omitempty"`
+	Username          string `json:"username"`
+	Message           string `json:"message"`
+	Token             string `json:"token,omitempty"`
+	ExpiresAt         string `json:"expires_at,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+	CSRFToken         string `json:"csrf_token,omitempty"`
+
+	PasswordResetRequired bool `json:"password_reset_required,omitempty"`
 }
 
 type LogoutRequest struct {
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TOTPDigits     = 6
+	TOTPPeriod     = 30 * time.Second
+	TOTPSkewSteps  = 1
+	TOTPSecretSize = 20
+
+	RecoveryCodeCount = 10
+	// RecoveryCodeSize is the number of random bytes in a recovery code:
+	// 80 bits, written as four groups of four base32 characters.
+	RecoveryCodeSize = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TwoFactorSecret struct {
+	UserID       int64     `json:"-"`
+	Secret       string    `json:"-"`
+	Confirmed    bool      `json:"-"`
+	LastUsedStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"-"`
+}
+
+type LoginChallenge struct {
+	ID             string    `json:"-"`
+	UserID         int64     `json:"-"`
+	Username       string    `json:"-"`
+	ChallengeToken string    `json:"-"`
+	Attempts       int       `json:"-"`
+	ExpiresAt      time.Time `json:"-"`
+	CreatedAt      time.Time `json:"-"`
+}
+
+// TOTPEnrollResponse carries the otpauth:// URI that authenticator apps
+// expect to receive encoded as a QR code.
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TOTPConfirmResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, TOTPSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", int(TOTPPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+func TOTPCode(secret string, step int64, digits int) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod), nil
+}
+
+// ValidateTOTPCode checks code against the steps around t and returns the
+// matched step so callers can refuse to accept the same code twice.
+func ValidateTOTPCode(secret, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for step := current - TOTPSkewSteps; step <= current+TOTPSkewSteps; step++ {
+		expected, err := TOTPCode(secret, step, TOTPDigits)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		raw := make([]byte, RecoveryCodeSize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(raw))
+		groups := make([]string, 0, len(code)/4)
+		for len(code) > 0 {
+			n := min(4, len(code))
+			groups = append(groups, code[:n])
+			code = code[n:]
+		}
+		codes = append(codes, strings.Join(groups, "-"))
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode uses a plain SHA-256 digest. That is only safe because
+// a code carries RecoveryCodeSize random bytes: 80 bits can't be brute-forced
+// offline from a leaked table, so a slow password hash buys nothing here.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,34 +1,81 @@
 package models
 
 import (
+	"strings"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID          int64     `json:"-"`
-	Username    string    `json:"username"`
-	Password    string    `json:"-"`
-	Email       string    `json:"email"`
-	Name        string    `json:"name"`
-	Surname     string    `json:"surname"`
-	Birthdate   time.Time `json:"birthdate"`
-	PhoneNumber string    `json:"phone_number"`
-	CreatedAt   time.Time `json:"-"`
-	UpdatedAt   time.Time `json:"-"`
+	ID              int64      `json:"-"`
+	PublicID        string     `json:"id"`
+	Username        string     `json:"username"`
+	Password        string     `json:"-"`
+	Email           string     `json:"email"`
+	Name            string     `json:"name"`
+	Surname         string     `json:"surname"`
+	Birthdate       NullDate   `json:"birthdate"`
+	PhoneNumber     string     `json:"phone_number"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	Bio             string     `json:"bio"`
+	Links           []string   `json:"links"`
+	Role            Role       `json:"role"`
+	CreatedAt       time.Time  `json:"-"`
+	UpdatedAt       time.Time  `json:"-"`
+	DeletedAt       *time.Time `json:"-"`
+
+	AvatarKey          string `json:"-"`
+	AvatarURL          string `json:"avatar_url"`
+	AvatarThumbnailURL string `json:"avatar_thumbnail_url"`
+
+	SuspendedAt           *time.Time `json:"-"`
+	PasswordResetRequired bool       `json:"-"`
 }
 
+// UserPublic.ID is the stable public_id other services should store when
+// referencing a user; usernames may be reused after an account is purged.
 type UserPublic struct {
-	Username string `json:"username"`
-	Name     string `json:"name"`
-	Surname  string `json:"surname"`
+	ID                 string `json:"id"`
+	Username           string `json:"username"`
+	Name               string `json:"name"`
+	Surname            string `json:"surname"`
+	Bio                string `json:"bio"`
+	AvatarURL          string `json:"avatar_url"`
+	AvatarThumbnailURL string `json:"avatar_thumbnail_url"`
+}
+
+type UserSearchResponse struct {
+	Users      []*UserPublic `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type BatchUserLookupRequest struct {
+	Usernames []string `json:"usernames,omitempty" validate:"max=100,dive,required,max=50"`
+	IDs       []string `json:"ids,omitempty" validate:"max=100,dive,required,uuid"`
+}
+
+type BatchUserLookupResponse struct {
+	Users    []*UserPublic `json:"users"`
+	NotFound []string      `json:"not_found"`
+}
+
+// reservedUsernames can't be registered: they are path segments of the API
+// (a user named "me" could never be looked up) or could be mistaken for
+// staff accounts. Existing accounts with these names keep working.
+var reservedUsernames = map[string]bool{
+	"me": true, "profile": true, "update": true, "batch": true, "password": true, "2fa": true,
+	"api": true, "auth": true, "admin": true, "media": true, "login": true, "logout": true,
+	"signin": true, "signup": true, "settings": true, "root": true, "system": true,
+	"support": true, "moderator": true, "security": true, "null": true, "undefined": true,
+}
+
+func IsReservedUsername(username string) bool {
+	return reservedUsernames[strings.ToLower(username)]
 }
 
 type SignInRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=50"`
+	Username string `json:"username" validate:"required,min=3,max=50,not_reserved"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6,max=100"`
+	Password string `json:"password" validate:"required,password_policy"`
 }
 
 type LoginRequest struct {
@@ -36,20 +83,33 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
-type UpdateUserRequest struct {
-	Name        string `json:"name"`
-	Surname     string `json:"surname"`
-	Email       string `json:"email" validate:"omitempty,email"`
-	Birthdate   string `json:"birthdate" validate:"omitempty,datetime=2025-03-01"`
-	PhoneNumber string `json:"phone_number" validate:"omitempty"`
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type DeleteAccountResponse struct {
+	Message    string `json:"message"`
+	PurgeAfter string `json:"purge_after"`
 }
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+type UpdateUserRequest struct {
+	Name        string       `json:"name"`
+	Surname     string       `json:"surname"`
+	Email       string       `json:"email" validate:"omitempty,email"`
+	Birthdate   OptionalDate `json:"birthdate" validate:"omitempty,age_range=13:120"`
+	PhoneNumber string       `json:"phone_number" validate:"omitempty,max=32"`
+	Bio         string       `json:"bio" validate:"omitempty,max=500"`
+	Links       []string     `json:"links,omitempty" validate:"omitempty,max=5,dive,http_url,max=200"`
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// UserPatchRequest is a JSON Merge Patch document for the own profile:
+// omitted fields are kept, null clears a field.
+type UserPatchRequest struct {
+	Name        Optional[string]   `json:"name" validate:"omitempty,max=100"`
+	Surname     Optional[string]   `json:"surname" validate:"omitempty,max=100"`
+	Email       Optional[string]   `json:"email" validate:"omitempty,email,max=100"`
+	Birthdate   OptionalDate       `json:"birthdate" validate:"omitempty,age_range=13:120"`
+	PhoneNumber Optional[string]   `json:"phone_number" validate:"omitempty,max=32"`
+	Bio         Optional[string]   `json:"bio" validate:"omitempty,max=500"`
+	Links       Optional[[]string] `json:"links" validate:"omitempty,max=5,dive,http_url,max=200"`
 }
@@ -0,0 +1,126 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106
+// with a more modest memory cost.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+}
+
+// Argon2idHasher encodes hashes in the PHC string format used by the
+// reference implementation:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) (*Argon2idHasher, error) {
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+	return &Argon2idHasher{params: params}, nil
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, encodedHash string) bool {
+	decoded, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), decoded.salt, decoded.params.Iterations,
+		decoded.params.Memory, decoded.params.Parallelism, uint32(len(decoded.key)))
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1
+}
+
+func (h *Argon2idHasher) Supports(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+func (h *Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	decoded, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return true
+	}
+	return decoded.version != argon2.Version ||
+		decoded.params.Memory < h.params.Memory ||
+		decoded.params.Iterations < h.params.Iterations ||
+		len(decoded.key) < argon2KeyLength
+}
+
+type argon2idHash struct {
+	version int
+	params  Argon2Params
+	salt    []byte
+	key     []byte
+}
+
+func decodeArgon2id(encodedHash string) (*argon2idHash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnsupportedHash
+	}
+
+	var decoded argon2idHash
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &decoded.version); err != nil {
+		return nil, ErrUnsupportedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d",
+		&decoded.params.Memory, &decoded.params.Iterations, &decoded.params.Parallelism); err != nil {
+		return nil, ErrUnsupportedHash
+	}
+
+	var err error
+	if decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnsupportedHash
+	}
+	if decoded.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(decoded.key) == 0 {
+		return nil, ErrUnsupportedHash
+	}
+	if decoded.params.Parallelism == 0 || decoded.params.Iterations == 0 {
+		return nil, ErrUnsupportedHash
+	}
+
+	return &decoded, nil
+}
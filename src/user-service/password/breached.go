@@ -0,0 +1,83 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const hashPrefixLength = 5
+
+// BreachedList is a local copy of breached password hashes in the format
+// of the Have I Been Pwned dumps: one upper-case SHA-1 per line, optionally
+// followed by ":<count>". Like the k-anonymity range API, hashes are
+// bucketed by their first five hex characters, and plaintext passwords are
+// never stored.
+type BreachedList struct {
+	ranges map[string]map[string]struct{}
+}
+
+func LoadBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedList{ranges: make(map[string]map[string]struct{})}
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: expected a SHA-1 hash", path, lineNumber)
+		}
+		list.add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (l *BreachedList) add(hash string) {
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+	suffixes, ok := l.ranges[prefix]
+	if !ok {
+		suffixes = make(map[string]struct{})
+		l.ranges[prefix] = suffixes
+	}
+	suffixes[suffix] = struct{}{}
+}
+
+// Range returns the hash suffixes known for a five character prefix.
+func (l *BreachedList) Range(prefix string) map[string]struct{} {
+	return l.ranges[strings.ToUpper(prefix)]
+}
+
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, found := l.Range(hash[:hashPrefixLength])[hash[hashPrefixLength:]]
+	return found
+}
+
+func (l *BreachedList) Len() int {
+	count := 0
+	for _, suffixes := range l.ranges {
+		count += len(suffixes)
+	}
+	return count
+}
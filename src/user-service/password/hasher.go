@@ -0,0 +1,110 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedHash = errors.New("unsupported password hash format")
+
+// Hasher turns passwords into self-describing encoded hashes. Supports
+// reports whether an encoded hash was produced by this algorithm, and
+// NeedsRehash whether it was produced with weaker parameters than the
+// hasher is currently configured with.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encodedHash string) bool
+	Supports(encodedHash string) bool
+	NeedsRehash(encodedHash string) bool
+}
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+type Config struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// argon2idMaxBytes has no cryptographic meaning, it only bounds the work
+// spent on a single password.
+const argon2idMaxBytes = 256
+
+// MaxPasswordBytes is the longest password the configured algorithm can
+// hash, for Policy.MaxBytes.
+func (c Config) MaxPasswordBytes() int {
+	if c.Algorithm == AlgorithmBcrypt {
+		return BcryptMaxBytes
+	}
+	return argon2idMaxBytes
+}
+
+// New builds a Manager that hashes new passwords with the configured
+// algorithm and still verifies hashes of every other supported one.
+func New(cfg Config) (*Manager, error) {
+	bcryptHasher, err := NewBcryptHasher(cfg.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	argon2Hasher, err := NewArgon2idHasher(cfg.Argon2)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Algorithm {
+	case AlgorithmBcrypt:
+		return NewManager(bcryptHasher, argon2Hasher), nil
+	case AlgorithmArgon2id:
+		return NewManager(argon2Hasher, bcryptHasher), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.Algorithm)
+	}
+}
+
+// Manager dispatches verification to whichever hasher understands the
+// stored hash, so switching algorithms does not lock existing users out.
+type Manager struct {
+	preferred Hasher
+	hashers   []Hasher
+}
+
+func NewManager(preferred Hasher, legacy ...Hasher) *Manager {
+	return &Manager{
+		preferred: preferred,
+		hashers:   append([]Hasher{preferred}, legacy...),
+	}
+}
+
+func (m *Manager) Hash(password string) (string, error) {
+	return m.preferred.Hash(password)
+}
+
+func (m *Manager) Verify(password, encodedHash string) bool {
+	for _, hasher := range m.hashers {
+		if hasher.Supports(encodedHash) {
+			return hasher.Verify(password, encodedHash)
+		}
+	}
+	return false
+}
+
+func (m *Manager) Supports(encodedHash string) bool {
+	for _, hasher := range m.hashers {
+		if hasher.Supports(encodedHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRehash is true for hashes of a non-preferred algorithm as well as
+// for preferred ones with outdated parameters.
+func (m *Manager) NeedsRehash(encodedHash string) bool {
+	if !m.preferred.Supports(encodedHash) {
+		return true
+	}
+	return m.preferred.NeedsRehash(encodedHash)
+}
@@ -0,0 +1,130 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RuleCharacterClasses = "character_classes"
+	RulePersonalInfo     = "personal_info"
+	RuleBreached         = "breached"
+)
+
+// minPersonalInfoLength keeps very short usernames or email local parts
+// from rejecting half the dictionary.
+const minPersonalInfoLength = 3
+
+// Violation is one failed policy rule. Message is meant to follow the field
+// name, e.g. "password " + "must be at least 8 characters long".
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v Violation) Error() string {
+	return v.Message
+}
+
+// Policy decides which new passwords are acceptable. MinLength counts
+// characters, MaxBytes the UTF-8 bytes the hasher gets. A nil Breached list
+// disables the breach check.
+type Policy struct {
+	MinLength           int
+	MaxBytes            int
+	MinCharacterClasses int
+	ForbidPersonalInfo  bool
+	Breached            *BreachedList
+}
+
+var DefaultPolicy = Policy{
+	MinLength:           8,
+	MaxBytes:            BcryptMaxBytes,
+	MinCharacterClasses: 2,
+	ForbidPersonalInfo:  true,
+}
+
+// Check returns every rule the password breaks. personalInfo holds values
+// such as the username and email the password must not contain.
+func (p *Policy) Check(password string, personalInfo ...string) []Violation {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d bytes long", p.MaxBytes),
+		})
+	}
+
+	if characterClasses(password) < p.MinCharacterClasses {
+		violations = append(violations, Violation{
+			Rule:    RuleCharacterClasses,
+			Message: fmt.Sprintf("must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinCharacterClasses),
+		})
+	}
+
+	if p.ForbidPersonalInfo && containsPersonalInfo(password, personalInfo) {
+		violations = append(violations, Violation{
+			Rule:    RulePersonalInfo,
+			Message: "must not contain your username or email",
+		})
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, Violation{
+			Rule:    RuleBreached,
+			Message: "has appeared in a data breach, choose a different one",
+		})
+	}
+
+	return violations
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	lowered := strings.ToLower(password)
+	for _, value := range personalInfo {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, found := strings.Cut(value, "@"); found {
+			value = local
+		}
+		if utf8.RuneCountInString(value) >= minPersonalInfoLength && strings.Contains(lowered, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// MaxE164Digits is the longest number E.164 allows, country code included.
+const MaxE164Digits = 15
+
+const minE164Digits = 8
+
+var ErrInvalidPhoneNumber = errors.New("invalid phone number")
+
+// Region describes how numbers written in national format are dialled in a
+// country. NationalLength is the number of digits after the trunk prefix,
+// or 0 when it varies and should not be checked.
+type Region struct {
+	CallingCode    string
+	TrunkPrefix    string
+	NationalLength int
+}
+
+var regions = map[string]Region{
+	"RU": {CallingCode: "7", TrunkPrefix: "8", NationalLength: 10},
+	"KZ": {CallingCode: "7", TrunkPrefix: "8", NationalLength: 10},
+	"BY": {CallingCode: "375", TrunkPrefix: "8", NationalLength: 9},
+	"UA": {CallingCode: "380", TrunkPrefix: "0", NationalLength: 9},
+	"US": {CallingCode: "1", TrunkPrefix: "1", NationalLength: 10},
+	"CA": {CallingCode: "1", TrunkPrefix: "1", NationalLength: 10},
+	"GB": {CallingCode: "44", TrunkPrefix: "0", NationalLength: 10},
+	"DE": {CallingCode: "49", TrunkPrefix: "0"},
+	"FR": {CallingCode: "33", TrunkPrefix: "0", NationalLength: 9},
+}
+
+// IsKnownRegion reports whether numbers in national format can be resolved
+// for the given ISO 3166-1 alpha-2 region code.
+func IsKnownRegion(region string) bool {
+	_, ok := regions[strings.ToUpper(region)]
+	return ok
+}
+
+// Normalize converts a phone number as typed by a user into E.164
+// ("+79991234567"). Numbers starting with "+" or the international "00"
+// prefix are taken as is; anything else is treated as a national number of
+// defaultRegion. Spaces, dashes, dots and parentheses are ignored.
+func Normalize(raw, defaultRegion string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || r == '\u00a0':
+		default:
+			return "", ErrInvalidPhoneNumber
+		}
+	}
+	number := b.String()
+
+	var digits string
+	switch {
+	case strings.HasPrefix(number, "+"):
+		digits = number[1:]
+	case strings.HasPrefix(number, "00"):
+		digits = number[2:]
+	default:
+		region, ok := regions[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return "", errors.New("unknown phone region " + defaultRegion)
+		}
+		national, err := region.nationalNumber(number)
+		if err != nil {
+			return "", err
+		}
+		digits = region.CallingCode + national
+	}
+
+	if len(digits) < minE164Digits || len(digits) > MaxE164Digits || digits[0] == '0' {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	return "+" + digits, nil
+}
+
+func (r Region) nationalNumber(number string) (string, error) {
+	if r.NationalLength == 0 {
+		return strings.TrimPrefix(number, r.TrunkPrefix), nil
+	}
+	if len(number) == len(r.TrunkPrefix)+r.NationalLength && strings.HasPrefix(number, r.TrunkPrefix) {
+		number = number[len(r.TrunkPrefix):]
+	}
+	if len(number) != r.NationalLength {
+		return "", ErrInvalidPhoneNumber
+	}
+	return number, nil
+}
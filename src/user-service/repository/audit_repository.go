@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"social-network/user-service/models"
+)
+
+type AuditRepository struct {
+	db dbtx
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// WithTx returns a repository that records entries in tx, so that they are
+// committed together with the action they describe.
+func (r *AuditRepository) WithTx(tx *sql.Tx) *AuditRepository {
+	return &AuditRepository{db: tx}
+}
+
+// Init creates the audit log. Entries belong to the target's user id:
+// usernames become free again once deleted accounts are purged, and a new
+// account must not inherit the history of the old one. Usernames are kept
+// by value for display, and there are no foreign keys, so history survives
+// the accounts it talks about.
+func (r *AuditRepository) Init() error {
+	query := `CREATE TABLE IF NOT EXISTS audit_log (
+		id SERIAL PRIMARY KEY,
+		actor_username VARCHAR(50) NOT NULL,
+		target_user_id INT,
+		target_username VARCHAR(50) NOT NULL,
+		action VARCHAR(50) NOT NULL,
+		details TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`
+	if _, err := r.db.Exec(query); err != nil {
+		return err
+	}
+
+	if err := r.migrateTargetUserID(); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`CREATE INDEX IF NOT EXISTS audit_log_target_user_idx ON audit_log (target_user_id, created_at DESC)`)
+	return err
+}
+
+// migrateTargetUserID adds target_user_id to logs created before it
+// existed. Old entries are attributed to the account that held the username
+// when they were written; entries about purged accounts keep a NULL id and
+// are no longer listed. The statements are sent as one query, which
+// Postgres runs as a single transaction.
+func (r *AuditRepository) migrateTargetUserID() error {
+	dataType, err := columnType(r.db, "audit_log", "target_user_id")
+	if err != nil || dataType != "" {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		ALTER TABLE audit_log ADD COLUMN target_user_id INT;
+		UPDATE audit_log a SET target_user_id = u.id FROM users u
+		 WHERE a.target_username = u.username AND a.created_at >= u.created_at;
+		DROP INDEX IF EXISTS audit_log_target_idx;
+	`)
+	return err
+}
+
+func (r *AuditRepository) Record(ctx context.Context, actor string, target *models.User, action, details string) error {
+	query := `INSERT INTO audit_log (actor_username, target_user_id, target_username, action, details, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.ExecContext(ctx, query, actor, target.ID, target.Username, action, details, time.Now())
+	return err
+}
+
+func (r *AuditRepository) ListForUser(ctx context.Context, targetID int64, limit, offset int) ([]*models.AuditEntry, error) {
+	query := `SELECT id, actor_username, target_username, action, details, created_at
+			  FROM audit_log WHERE target_user_id = $1
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT $2 OFFSET $3`
+
+	return r.list(ctx, query, targetID, limit, offset)
+}
+
+// ListAllForUser returns every entry about a user, oldest first.
+func (r *AuditRepository) ListAllForUser(ctx context.Context, targetID int64) ([]*models.AuditEntry, error) {
+	query := `SELECT id, actor_username, target_username, action, details, created_at
+			  FROM audit_log WHERE target_user_id = $1
+			  ORDER BY created_at, id`
+
+	return r.list(ctx, query, targetID)
+}
+
+func (r *AuditRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.AuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorUsername,
+			&entry.TargetUsername,
+			&entry.Action,
+			&entry.Details,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"social-network/user-service/models"
+)
+
+type PhoneVerificationRepository struct {
+	db *sql.DB
+}
+
+func NewPhoneVerificationRepository(db *sql.DB) *PhoneVerificationRepository {
+	return &PhoneVerificationRepository{db: db}
+}
+
+func (r *PhoneVerificationRepository) Init() error {
+	query := `CREATE TABLE IF NOT EXISTS phone_verifications (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		phone_number VARCHAR(20) NOT NULL,
+		code_hash VARCHAR(64) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`
+
+	_, err := r.db.Exec(query)
+	return err
+}
+
+// Save replaces any pending verification of the user.
+func (r *PhoneVerificationRepository) Save(ctx context.Context, verification *models.PhoneVerification) error {
+	query := `INSERT INTO phone_verifications (user_id, phone_number, code_hash, attempts, expires_at, created_at)
+			  VALUES ($1, $2, $3, 0, $4, $5)
+			  ON CONFLICT (user_id) DO UPDATE SET
+			  phone_number = EXCLUDED.phone_number,
+			  code_hash = EXCLUDED.code_hash,
+			  attempts = 0,
+			  expires_at = EXCLUDED.expires_at,
+			  created_at = EXCLUDED.created_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		verification.UserID,
+		verification.PhoneNumber,
+		verification.CodeHash,
+		verification.ExpiresAt,
+		verification.CreatedAt,
+	)
+	return err
+}
+
+func (r *PhoneVerificationRepository) Get(ctx context.Context, userID int64) (*models.PhoneVerification, error) {
+	query := `SELECT user_id, phone_number, code_hash, attempts, expires_at, created_at
+			  FROM phone_verifications WHERE user_id = $1`
+
+	var verification models.PhoneVerification
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&verification.UserID,
+		&verification.PhoneNumber,
+		&verification.CodeHash,
+		&verification.Attempts,
+		&verification.ExpiresAt,
+		&verification.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("no pending phone verification")
+		}
+		return nil, err
+	}
+
+	return &verification, nil
+}
+
+func (r *PhoneVerificationRepository) IncrementAttempts(ctx context.Context, userID int64) error {
+	query := `UPDATE phone_verifications SET attempts = attempts + 1 WHERE user_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *PhoneVerificationRepository) Delete(ctx context.Context, userID int64) error {
+	query := `DELETE FROM phone_verifications WHERE user_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *PhoneVerificationRepository) CleanExpired(ctx context.Context) error {
+	query := `DELETE FROM phone_verifications WHERE expires_at < $1`
+	_, err := r.db.ExecContext(ctx, query, time.Now())
+	return err
+}
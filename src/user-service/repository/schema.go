@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// schemaTables are the tables created by the repositories' Init methods.
+var schemaTables = []string{
+	"users",
+	"sessions",
+	"user_totp",
+	"recovery_codes",
+	"login_challenges",
+	"audit_log",
+	"phone_verifications",
+}
+
+// CheckSchema reports tables that are missing, e.g. when the database was
+// recreated underneath a running service.
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t FROM unnest($1::text[]) AS t WHERE to_regclass(t) IS NULL`,
+		pq.Array(schemaTables),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		missing = append(missing, table)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,13 +12,18 @@ import (
 )
 
 type SessionRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 func NewSessionRepository(db *sql.DB) *SessionRepository {
 	return &SessionRepository{db: db}
 }
 
+// WithTx returns a repository that runs its queries in tx.
+func (r *SessionRepository) WithTx(tx *sql.Tx) *SessionRepository {
+	return &SessionRepository{db: tx}
+}
+
 func (r *SessionRepository) Init() error {
 	query := `CREATE TABLE IF NOT EXISTS sessions (
 		id SERIAL PRIMARY KEY,
@@ -31,7 +38,7 @@ func (r *SessionRepository) Init() error {
 	return err
 }
 
-func (r *SessionRepository) CreateSession(userID int64, username string, duration time.Duration) (*models.Session, error) {
+func (r *SessionRepository) CreateSession(ctx context.Context, userID int64, username string, duration time.Duration) (*models.Session, error) {
 	token := uuid.New().String()
 	expiresAt := time.Now().Add(duration)
 
@@ -47,7 +54,7 @@ func (r *SessionRepository) CreateSession(userID int64, username string, duratio
 			  VALUES ($1, $2, $3, $4, $5)
 			  RETURNING id`
 
-	err := r.db.QueryRow(query,
+	err := r.db.QueryRowContext(ctx, query,
 		session.UserID,
 		session.Username,
 		session.SessionToken,
@@ -62,12 +69,12 @@ func (r *SessionRepository) CreateSession(userID int64, username string, duratio
 	return session, nil
 }
 
-func (r *SessionRepository) GetSessionByToken(token string) (*models.Session, error) {
+func (r *SessionRepository) GetSessionByToken(ctx context.Context, token string) (*models.Session, error) {
 	query := `SELECT id, user_id, username, session_token, expires_at, created_at
 			  FROM sessions WHERE session_token = $1`
 
 	var session models.Session
-	err := r.db.QueryRow(query, token).Scan(
+	err := r.db.QueryRowContext(ctx, query, token).Scan(
 		&session.ID,
 		&session.UserID,
 		&session.Username,
@@ -83,20 +90,80 @@ func (r *SessionRepository) GetSessionByToken(token string) (*models.Session, er
 	return &session, nil
 }
 
-func (r *SessionRepository) DeleteSession(token string) error {
+func (r *SessionRepository) GetUserSessions(ctx context.Context, userID int64) ([]*models.Session, error) {
+	query := `SELECT id, user_id, username, session_token, expires_at, created_at
+			  FROM sessions WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.Username,
+			&session.SessionToken,
+			&session.ExpiresAt,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *SessionRepository) DeleteSession(ctx context.Context, token string) error {
 	query := `DELETE FROM sessions WHERE session_token = $1`
-	_, err := r.db.Exec(query, token)
+	_, err := r.db.ExecContext(ctx, query, token)
 	return err
 }
 
-func (r *SessionRepository) DeleteAllUserSessions(username string) error {
+func (r *SessionRepository) DeleteAllUserSessions(ctx context.Context, username string) error {
 	query := `DELETE FROM sessions WHERE username = $1`
-	_, err := r.db.Exec(query, username)
+	_, err := r.db.ExecContext(ctx, query, username)
 	return err
 }
 
-func (r *SessionRepository) CleanExpiredSessions() error {
+// DeleteUserSession deletes one session by id, and only if it belongs to
+// username.
+func (r *SessionRepository) DeleteUserSession(ctx context.Context, username, id string) error {
+	query := `DELETE FROM sessions WHERE username = $1 AND id::text = $2`
+	result, err := r.db.ExecContext(ctx, query, username, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("session not found")
+	}
+	return nil
+}
+
+func (r *SessionRepository) DeleteOtherUserSessions(ctx context.Context, username, keepToken string) error {
+	query := `DELETE FROM sessions WHERE username = $1 AND session_token <> $2`
+	_, err := r.db.ExecContext(ctx, query, username, keepToken)
+	return err
+}
+
+func (r *SessionRepository) CountActiveSessions(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE expires_at > $1`, time.Now()).Scan(&count)
+	return count, err
+}
+
+func (r *SessionRepository) CleanExpiredSessions(ctx context.Context) error {
 	query := `DELETE FROM sessions WHERE expires_at < $1`
-	_, err := r.db.Exec(query, time.Now())
+	_, err := r.db.ExecContext(ctx, query, time.Now())
 	return err
 }
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"social-network/user-service/models"
+)
+
+// ErrTwoFactorNotEnrolled is returned by GetSecret for users that never
+// started two-factor enrollment.
+var ErrTwoFactorNotEnrolled = errors.New("two-factor authentication not enrolled")
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+func (r *TwoFactorRepository) Init() error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS user_totp (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret VARCHAR(64) NOT NULL,
+			confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+			last_used_step BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS recovery_codes (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash VARCHAR(64) NOT NULL,
+			used_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS login_challenges (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			username VARCHAR(50) NOT NULL,
+			challenge_token VARCHAR(100) UNIQUE NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := r.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *TwoFactorRepository) SaveSecret(ctx context.Context, userID int64, secret string) error {
+	query := `INSERT INTO user_totp (user_id, secret, confirmed, last_used_step, created_at)
+			  VALUES ($1, $2, FALSE, 0, $3)
+			  ON CONFLICT (user_id) DO UPDATE SET
+			  secret = EXCLUDED.secret,
+			  confirmed = FALSE,
+			  last_used_step = 0,
+			  created_at = EXCLUDED.created_at`
+
+	_, err := r.db.ExecContext(ctx, query, userID, secret, time.Now())
+	return err
+}
+
+func (r *TwoFactorRepository) GetSecret(ctx context.Context, userID int64) (*models.TwoFactorSecret, error) {
+	query := `SELECT user_id, secret, confirmed, last_used_step, created_at
+			  FROM user_totp WHERE user_id = $1`
+
+	var secret models.TwoFactorSecret
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&secret.UserID,
+		&secret.Secret,
+		&secret.Confirmed,
+		&secret.LastUsedStep,
+		&secret.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+func (r *TwoFactorRepository) IsEnabled(ctx context.Context, userID int64) (bool, error) {
+	var enabled bool
+	query := `SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND confirmed)`
+
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&enabled)
+	return enabled, err
+}
+
+func (r *TwoFactorRepository) ConfirmSecret(ctx context.Context, userID int64, step int64) error {
+	query := `UPDATE user_totp SET confirmed = TRUE, last_used_step = $1 WHERE user_id = $2`
+	_, err := r.db.ExecContext(ctx, query, step, userID)
+	return err
+}
+
+// MarkStepUsed records the TOTP step of an accepted code. It only succeeds
+// for steps newer than the last one, so a code can't be replayed.
+func (r *TwoFactorRepository) MarkStepUsed(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `UPDATE user_totp SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+	result, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *TwoFactorRepository) DeleteSecret(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`,
+			userID, hash, time.Now(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	query := `UPDATE recovery_codes SET used_at = $1
+			  WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *TwoFactorRepository) ListRecoveryCodes(ctx context.Context, userID int64) ([]models.RecoveryCodeExport, error) {
+	query := `SELECT created_at, used_at FROM recovery_codes WHERE user_id = $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []models.RecoveryCodeExport{}
+	for rows.Next() {
+		var code models.RecoveryCodeExport
+		var usedAt sql.NullTime
+		if err := rows.Scan(&code.CreatedAt, &usedAt); err != nil {
+			return nil, err
+		}
+		if usedAt.Valid {
+			code.UsedAt = &usedAt.Time
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+func (r *TwoFactorRepository) CreateChallenge(ctx context.Context, userID int64, username string, duration time.Duration) (*models.LoginChallenge, error) {
+	challenge := &models.LoginChallenge{
+		UserID:         userID,
+		Username:       username,
+		ChallengeToken: uuid.New().String(),
+		ExpiresAt:      time.Now().Add(duration),
+		CreatedAt:      time.Now(),
+	}
+
+	query := `INSERT INTO login_challenges (user_id, username, challenge_token, expires_at, created_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query,
+		challenge.UserID,
+		challenge.Username,
+		challenge.ChallengeToken,
+		challenge.ExpiresAt,
+		challenge.CreatedAt,
+	).Scan(&challenge.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (r *TwoFactorRepository) GetChallengeByToken(ctx context.Context, token string) (*models.LoginChallenge, error) {
+	query := `SELECT id, user_id, username, challenge_token, attempts, expires_at, created_at
+			  FROM login_challenges WHERE challenge_token = $1`
+
+	var challenge models.LoginChallenge
+	err := r.db.QueryRowContext(ctx, query, token).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.Username,
+		&challenge.ChallengeToken,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("login challenge not found")
+		}
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (r *TwoFactorRepository) IncrementChallengeAttempts(ctx context.Context, token string) error {
+	query := `UPDATE login_challenges SET attempts = attempts + 1 WHERE challenge_token = $1`
+	_, err := r.db.ExecContext(ctx, query, token)
+	return err
+}
+
+func (r *TwoFactorRepository) DeleteChallenge(ctx context.Context, token string) error {
+	query := `DELETE FROM login_challenges WHERE challenge_token = $1`
+	_, err := r.db.ExecContext(ctx, query, token)
+	return err
+}
+
+func (r *TwoFactorRepository) CleanExpiredChallenges(ctx context.Context) error {
+	query := `DELETE FROM login_challenges WHERE expires_at < $1`
+	_, err := r.db.ExecContext(ctx, query, time.Now())
+	return err
+}
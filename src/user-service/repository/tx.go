@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// dbtx is the part of *sql.DB the repositories use. *sql.Tx implements it
+// too, so WithTx can point a repository at a transaction.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// InTx runs fn in a transaction on db and commits it if fn succeeds. Use
+// the repositories' WithTx methods inside fn to join the transaction.
+func InTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// columnType returns the data_type of a column as reported by
+// information_schema, or "" if the column does not exist. Init methods use
+// it to run ALTER TABLE only when there is something to migrate: ALTER
+// TABLE takes an exclusive lock even when it turns out to be a no-op.
+func columnType(db dbtx, table, column string) (string, error) {
+	var dataType string
+	err := db.QueryRowContext(context.Background(),
+		`SELECT data_type FROM information_schema.columns
+		 WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`,
+		table, column,
+	).Scan(&dataType)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return dataType, err
+}
@@ -1,45 +1,109 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
 	"social-network/user-service/models"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db      dbtx
+	replica *sql.DB
 }
 
 func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// WithTx returns a repository that runs its queries, reads included, in tx.
+func (r *UserRepository) WithTx(tx *sql.Tx) *UserRepository {
+	return &UserRepository{db: tx}
+}
+
+// SetReadReplica routes reads that tolerate replication lag (public
+// profiles, the directory and batch lookups) to a replica. Writes and reads
+// that must observe the caller's own changes always go to the primary.
+func (r *UserRepository) SetReadReplica(replica *sql.DB) {
+	r.replica = replica
+}
+
+// queryReplica runs a read-only query on the replica, falling back to the
+// primary when there is none or it cannot serve the query.
+func (r *UserRepository) queryReplica(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if r.replica != nil {
+		rows, err := r.replica.QueryContext(ctx, query, args...)
+		if err == nil || ctx.Err() != nil {
+			return rows, err
+		}
+		slog.Warn("read replica query failed, using primary", "error", err)
+	}
+	return r.db.QueryContext(ctx, query, args...)
+}
+
 func (r *UserRepository) Init() error {
-	query := `CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		username VARCHAR(50) UNIQUE NOT NULL,
-		password VARCHAR(100) NOT NULL,
-		email VARCHAR(100) UNIQUE NOT NULL,
-		name VARCHAR(100),
-		surname VARCHAR(100),
-		birthdate DATE,
-		phone_number VARCHAR(20),
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	)`
-
-	_, err := r.db.Exec(query)
-	return err
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS users (
+			id SERIAL PRIMARY KEY,
+			username VARCHAR(50) UNIQUE NOT NULL,
+			password TEXT NOT NULL,
+			email VARCHAR(100) UNIQUE NOT NULL,
+			name VARCHAR(100),
+			surname VARCHAR(100),
+			birthdate DATE,
+			phone_number VARCHAR(20),
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid()`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS users_public_id_idx ON users (public_id)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS links TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(200) NOT NULL DEFAULT ''`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(300) NOT NULL DEFAULT ''`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_thumbnail_url VARCHAR(300) NOT NULL DEFAULT ''`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified_at TIMESTAMP`,
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS users_directory_search_idx ON users
+			USING GIN ((` + directorySearchExpr + `) gin_trgm_ops)`,
+	}
+
+	for _, query := range queries {
+		if _, err := r.db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	// PHC strings of argon2id hashes don't fit the original VARCHAR(100).
+	// Check first, so that startups don't lock users once it is migrated.
+	dataType, err := columnType(r.db, "users", "password")
+	if err != nil {
+		return err
+	}
+	if dataType != "text" {
+		if _, err := r.db.Exec(`ALTER TABLE users ALTER COLUMN password TYPE TEXT`); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *UserRepository) CreateUser(user *models.User) error {
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
 	query := `INSERT INTO users (username, password, email, name, surname, birthdate, phone_number, created_at, updated_at)
 			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-			  RETURNING id`
+			  RETURNING id, public_id`
 
-	err := r.db.QueryRow(query,
+	err := r.db.QueryRowContext(ctx, query,
 		user.Username,
 		user.Password,
 		user.Email,
@@ -49,31 +113,70 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 		user.PhoneNumber,
 		time.Now(),
 		time.Now(),
-	).Scan(&user.ID)
+	).Scan(&user.ID, &user.PublicID)
 
 	return err
 }
 
-func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
-	query := `SELECT id, username, password, email, name, surname, birthdate, phone_number, created_at, updated_at
-			  FROM users WHERE username = $1`
+const userColumns = `id, username, password, email, name, surname, birthdate, phone_number, created_at, updated_at,
+			  deleted_at, role, suspended_at, password_reset_required, public_id,
+			  bio, links, avatar_key, avatar_url, avatar_thumbnail_url, phone_verified_at`
 
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
 	var user models.User
-	var birthdate sql.NullTime
+	var deletedAt sql.NullTime
+	var suspendedAt sql.NullTime
+	var phoneVerifiedAt sql.NullTime
 
-	err := r.db.QueryRow(query, username).Scan(
+	err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.Password,
 		&user.Email,
 		&user.Name,
 		&user.Surname,
-		&birthdate,
+		&user.Birthdate,
 		&user.PhoneNumber,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&deletedAt,
+		&user.Role,
+		&suspendedAt,
+		&user.PasswordResetRequired,
+		&user.PublicID,
+		&user.Bio,
+		pq.Array(&user.Links),
+		&user.AvatarKey,
+		&user.AvatarURL,
+		&user.AvatarThumbnailURL,
+		&phoneVerifiedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
 
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
+	if phoneVerifiedAt.Valid {
+		user.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `SELECT ` + userColumns + `
+			  FROM users WHERE username = $1`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
@@ -81,48 +184,367 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 		return nil, err
 	}
 
-	if birthdate.Valid {
-		user.Birthdate = birthdate.Time
+	return user, nil
+}
+
+// GetUserByUsernameFromReplica is GetUserByUsername for public views, where
+// a slightly stale row is acceptable.
+func (r *UserRepository) GetUserByUsernameFromReplica(ctx context.Context, username string) (*models.User, error) {
+	query := `SELECT ` + userColumns + `
+			  FROM users WHERE username = $1`
+
+	rows, err := r.queryReplica(ctx, query, username)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return &user, nil
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("user not found")
+	}
+	return scanUser(rows)
 }
 
-func (r *UserRepository) UserExists(username string) (bool, error) {
+func (r *UserRepository) UserExists(ctx context.Context, username string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`
 
-	err := r.db.QueryRow(query, username).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, username).Scan(&exists)
 	return exists, err
 }
 
-func (r *UserRepository) EmailExists(email string) (bool, error) {
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
 
-	err := r.db.QueryRow(query, email).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&exists)
 	return exists, err
 }
 
-func (r *UserRepository) UpdateUser(user *models.User) error {
+func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	query := `UPDATE users SET 
 			  name = $1, 
 			  surname = $2, 
 			  email = $3, 
 			  birthdate = $4, 
 			  phone_number = $5, 
-			  updated_at = $6
-			  WHERE username = $7`
+			  phone_verified_at = CASE WHEN phone_number IS DISTINCT FROM $5 THEN NULL ELSE phone_verified_at END,
+			  bio = $6,
+			  links = $7,
+			  updated_at = $8
+			  WHERE username = $9`
+
+	links := user.Links
+	if links == nil {
+		links = []string{}
+	}
 
-	_, err := r.db.Exec(query,
+	_, err := r.db.ExecContext(ctx, query,
 		user.Name,
 		user.Surname,
 		user.Email,
 		user.Birthdate,
 		user.PhoneNumber,
+		user.Bio,
+		pq.Array(links),
 		time.Now(),
 		user.Username,
 	)
 
 	return err
 }
+
+func (r *UserRepository) SoftDeleteUser(ctx context.Context, username string) (time.Time, error) {
+	deletedAt := time.Now()
+	query := `UPDATE users SET deleted_at = $1, updated_at = $1 WHERE username = $2 AND deleted_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, deletedAt, username)
+	return deletedAt, err
+}
+
+func (r *UserRepository) RestoreUser(ctx context.Context, username string) error {
+	query := `UPDATE users SET deleted_at = NULL, updated_at = $1 WHERE username = $2`
+	_, err := r.db.ExecContext(ctx, query, time.Now(), username)
+	return err
+}
+
+// UpdateUserIfUnmodified writes the editable profile fields only if the row
+// still has the given updated_at, and refreshes user.UpdatedAt on success.
+func (r *UserRepository) UpdateUserIfUnmodified(ctx context.Context, user *models.User, lastUpdatedAt time.Time) (bool, error) {
+	query := `UPDATE users SET
+			  name = $1,
+			  surname = $2,
+			  email = $3,
+			  birthdate = $4,
+			  phone_number = $5,
+			  phone_verified_at = CASE WHEN phone_number IS DISTINCT FROM $5 THEN NULL ELSE phone_verified_at END,
+			  bio = $6,
+			  links = $7,
+			  updated_at = $8
+			  WHERE username = $9 AND updated_at = $10
+			  RETURNING updated_at`
+
+	links := user.Links
+	if links == nil {
+		links = []string{}
+	}
+
+	err := r.db.QueryRowContext(ctx, query,
+		user.Name,
+		user.Surname,
+		user.Email,
+		user.Birthdate,
+		user.PhoneNumber,
+		user.Bio,
+		pq.Array(links),
+		time.Now(),
+		user.Username,
+		lastUpdatedAt,
+	).Scan(&user.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// MarkPhoneVerified only succeeds while the profile still holds the number
+// the code was sent to.
+func (r *UserRepository) MarkPhoneVerified(ctx context.Context, username, phoneNumber string) (time.Time, error) {
+	verifiedAt := time.Now()
+	query := `UPDATE users SET phone_verified_at = $1, updated_at = $1
+			  WHERE username = $2 AND phone_number = $3`
+
+	result, err := r.db.ExecContext(ctx, query, verifiedAt, username, phoneNumber)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rowsAffected == 0 {
+		return time.Time{}, errors.New("phone number changed since the code was sent")
+	}
+
+	return verifiedAt, nil
+}
+
+// PhoneNumberVerifiedByOther reports whether another live account has
+// already verified the number.
+func (r *UserRepository) PhoneNumberVerifiedByOther(ctx context.Context, phoneNumber, username string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM users
+			  WHERE phone_number = $1 AND username <> $2
+			  AND phone_verified_at IS NOT NULL AND deleted_at IS NULL)`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, phoneNumber, username).Scan(&exists)
+	return exists, err
+}
+
+func (r *UserRepository) UpdateAvatar(ctx context.Context, username, key, url, thumbnailURL string) error {
+	query := `UPDATE users SET avatar_key = $1, avatar_url = $2, avatar_thumbnail_url = $3, updated_at = $4
+			  WHERE username = $5`
+	return r.execOnUser(ctx, query, key, url, thumbnailURL, time.Now(), username)
+}
+
+// ListPurgeableAvatarKeys returns avatar keys of accounts that the next
+// PurgeDeletedUsers call with the same cutoff will remove.
+func (r *UserRepository) ListPurgeableAvatarKeys(ctx context.Context, before time.Time) ([]string, error) {
+	query := `SELECT avatar_key FROM users
+			  WHERE deleted_at IS NOT NULL AND deleted_at < $1 AND avatar_key <> ''`
+
+	rows, err := r.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// PurgeDeletedUsers hard-deletes accounts soft-deleted before the given
+// moment. Dependent rows go with them through ON DELETE CASCADE.
+func (r *UserRepository) PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// directorySearchExpr only covers public profile fields, so directory
+// search can't be used to probe emails or phone numbers.
+const directorySearchExpr = `username || ' ' || COALESCE(name, '') || ' ' || COALESCE(surname, '')`
+
+// SearchPublicUsers lists active accounts ordered by username, starting
+// after the given one. A non-empty search matches by substring or trigram
+// similarity.
+func (r *UserRepository) SearchPublicUsers(ctx context.Context, search, afterUsername string, limit int) ([]*models.UserPublic, error) {
+	query := `SELECT ` + publicUserColumns + `
+			  FROM users
+			  WHERE deleted_at IS NULL AND suspended_at IS NULL
+			  AND username > $1
+			  AND ($2 = '' OR (` + directorySearchExpr + `) ILIKE $3 OR (` + directorySearchExpr + `) % $2)
+			  ORDER BY username
+			  LIMIT $4`
+
+	pattern := "%" + escapeLike(search) + "%"
+	rows, err := r.queryReplica(ctx, query, afterUsername, search, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPublicUsers(rows)
+}
+
+// GetPublicUsersBatch resolves any mix of usernames and public IDs with a
+// single query. Unknown keys, like deleted and suspended accounts, are
+// simply absent from the result. Public IDs match in any letter case, but
+// come back in lowercase, the way Postgres renders UUIDs.
+func (r *UserRepository) GetPublicUsersBatch(ctx context.Context, usernames, publicIDs []string) ([]*models.UserPublic, error) {
+	query := `SELECT ` + publicUserColumns + `
+			  FROM users
+			  WHERE deleted_at IS NULL AND suspended_at IS NULL
+			  AND (username = ANY($1) OR public_id::text = ANY($2))
+			  ORDER BY username`
+
+	lowercaseIDs := make([]string, len(publicIDs))
+	for i, id := range publicIDs {
+		lowercaseIDs[i] = strings.ToLower(id)
+	}
+
+	rows, err := r.queryReplica(ctx, query, pq.Array(usernames), pq.Array(lowercaseIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPublicUsers(rows)
+}
+
+const publicUserColumns = `public_id, username, COALESCE(name, ''), COALESCE(surname, ''),
+			  bio, avatar_url, avatar_thumbnail_url`
+
+func scanPublicUsers(rows *sql.Rows) ([]*models.UserPublic, error) {
+	users := []*models.UserPublic{}
+	for rows.Next() {
+		var user models.UserPublic
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Name,
+			&user.Surname,
+			&user.Bio,
+			&user.AvatarURL,
+			&user.AvatarThumbnailURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// SearchUsers matches the query against username, email, name and surname.
+// It is meant for staff tooling and returns deleted and suspended accounts too.
+func (r *UserRepository) SearchUsers(ctx context.Context, search string, limit, offset int) ([]*models.User, error) {
+	query := `SELECT ` + userColumns + `
+			  FROM users
+			  WHERE $1 = '' OR username ILIKE $2 OR email ILIKE $2 OR name ILIKE $2 OR surname ILIKE $2
+			  ORDER BY id
+			  LIMIT $3 OFFSET $4`
+
+	pattern := "%" + escapeLike(search) + "%"
+	rows, err := r.db.QueryContext(ctx, query, search, pattern, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, username string, role models.Role) error {
+	query := `UPDATE users SET role = $1, updated_at = $2 WHERE username = $3`
+	return r.execOnUser(ctx, query, role, time.Now(), username)
+}
+
+func (r *UserRepository) SetSuspended(ctx context.Context, username string, suspended bool) error {
+	if suspended {
+		query := `UPDATE users SET suspended_at = $1, updated_at = $1 WHERE username = $2`
+		return r.execOnUser(ctx, query, time.Now(), username)
+	}
+	query := `UPDATE users SET suspended_at = NULL, updated_at = $1 WHERE username = $2`
+	return r.execOnUser(ctx, query, time.Now(), username)
+}
+
+func (r *UserRepository) SetPasswordResetRequired(ctx context.Context, username string, required bool) error {
+	query := `UPDATE users SET password_reset_required = $1, updated_at = $2 WHERE username = $3`
+	return r.execOnUser(ctx, query, required, time.Now(), username)
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, username, passwordHash string) error {
+	query := `UPDATE users SET password = $1, password_reset_required = FALSE, updated_at = $2 WHERE username = $3`
+	return r.execOnUser(ctx, query, passwordHash, time.Now(), username)
+}
+
+// ReplacePasswordHash swaps a hash for an equivalent one of the same
+// password, e.g. after a cost upgrade. It is a no-op if the password was
+// changed in the meantime and leaves updated_at alone.
+func (r *UserRepository) ReplacePasswordHash(ctx context.Context, username, oldHash, newHash string) error {
+	query := `UPDATE users SET password = $1 WHERE username = $2 AND password = $3`
+	_, err := r.db.ExecContext(ctx, query, newHash, username, oldHash)
+	return err
+}
+
+func (r *UserRepository) execOnUser(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"social-network/user-service/models"
+	"social-network/user-service/repository"
+)
+
+// AccountDeletionGracePeriod is how long a soft-deleted account can still
+// be restored by logging in before the purge job removes it for good.
+const AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+func (s *UserService) DeleteAccount(ctx context.Context, username, password string) (*models.DeleteAccountResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteAccount")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.checkPassword(ctx, user, password) {
+		return nil, errors.New("invalid credentials")
+	}
+
+	deletedAt, err := s.repo.SoftDeleteUser(ctx, user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.DeleteAllUserSessions(ctx, user.Username); err != nil {
+		return nil, err
+	}
+
+	return &models.DeleteAccountResponse{
+		Message:    "Account scheduled for deletion",
+		PurgeAfter: deletedAt.Add(AccountDeletionGracePeriod).Format(time.RFC3339),
+	}, nil
+}
+
+func (s *UserService) PurgeDeletedAccounts(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "UserService.PurgeDeletedAccounts")
+	defer span.End()
+
+	return s.repo.PurgeDeletedUsers(ctx, time.Now().Add(-AccountDeletionGracePeriod))
+}
+
+func (s *UserService) ExportUserData(ctx context.Context, username string) (*models.UserDataExport, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ExportUserData")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: models.ProfileExport{
+			PublicID:              user.PublicID,
+			Username:              user.Username,
+			Email:                 user.Email,
+			Name:                  user.Name,
+			Surname:               user.Surname,
+			Birthdate:             user.Birthdate,
+			PhoneNumber:           user.PhoneNumber,
+			PhoneVerifiedAt:       user.PhoneVerifiedAt,
+			Bio:                   user.Bio,
+			Links:                 user.Links,
+			AvatarURL:             user.AvatarURL,
+			Role:                  user.Role,
+			SuspendedAt:           user.SuspendedAt,
+			PasswordResetRequired: user.PasswordResetRequired,
+			CreatedAt:             user.CreatedAt,
+			UpdatedAt:             user.UpdatedAt,
+			DeletedAt:             user.DeletedAt,
+		},
+		Sessions: []models.SessionExport{},
+		AuditLog: []models.AuditExport{},
+	}
+
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, models.SessionExport{
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		})
+	}
+
+	entries, err := s.auditRepo.ListAllForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		export.AuditLog = append(export.AuditLog, models.AuditExport{
+			Action:    entry.Action,
+			Details:   entry.Details,
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+
+	secret, err := s.twoFactorRepo.GetSecret(ctx, user.ID)
+	if errors.Is(err, repository.ErrTwoFactorNotEnrolled) {
+		return export, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	recoveryCodes, err := s.twoFactorRepo.ListRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.TwoFactor = &models.TwoFactorExport{
+		Enabled:       secret.Confirmed,
+		EnrolledAt:    secret.CreatedAt,
+		RecoveryCodes: recoveryCodes,
+	}
+
+	return export, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"social-network/user-service/models"
+	"social-network/user-service/repository"
+)
+
+const (
+	AuditActionSuspend       = "suspend"
+	AuditActionUnsuspend     = "unsuspend"
+	AuditActionPasswordReset = "force_password_reset"
+	AuditActionRoleChange    = "role_change"
+)
+
+var roleRank = map[models.Role]int{
+	models.RoleUser:      0,
+	models.RoleModerator: 1,
+	models.RoleAdmin:     2,
+}
+
+type AdminService struct {
+	db          *sql.DB
+	repo        *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	auditRepo   *repository.AuditRepository
+}
+
+func NewAdminService(
+	db *sql.DB,
+	repo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	auditRepo *repository.AuditRepository,
+) *AdminService {
+	return &AdminService{
+		db:          db,
+		repo:        repo,
+		sessionRepo: sessionRepo,
+		auditRepo:   auditRepo,
+	}
+}
+
+func (s *AdminService) SearchUsers(ctx context.Context, query string, limit, offset int) ([]*models.AdminUserView, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.SearchUsers")
+	defer span.End()
+
+	users, err := s.repo.SearchUsers(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]*models.AdminUserView, 0, len(users))
+	for _, user := range users {
+		views = append(views, &models.AdminUserView{
+			ID:                    user.ID,
+			Username:              user.Username,
+			Email:                 user.Email,
+			Name:                  user.Name,
+			Surname:               user.Surname,
+			Role:                  user.Role,
+			SuspendedAt:           user.SuspendedAt,
+			PasswordResetRequired: user.PasswordResetRequired,
+			DeletedAt:             user.DeletedAt,
+			CreatedAt:             user.CreatedAt,
+		})
+	}
+	return views, nil
+}
+
+func (s *AdminService) SuspendUser(ctx context.Context, actor, target, reason string) error {
+	ctx, span := tracer.Start(ctx, "AdminService.SuspendUser")
+	defer span.End()
+
+	user, err := s.authorizeAction(ctx, actor, target)
+	if err != nil {
+		return err
+	}
+
+	return s.apply(ctx, actor, user, AuditActionSuspend, reason, func(users *repository.UserRepository, sessions *repository.SessionRepository) error {
+		if err := users.SetSuspended(ctx, user.Username, true); err != nil {
+			return err
+		}
+		return sessions.DeleteAllUserSessions(ctx, user.Username)
+	})
+}
+
+func (s *AdminService) UnsuspendUser(ctx context.Context, actor, target string) error {
+	ctx, span := tracer.Start(ctx, "AdminService.UnsuspendUser")
+	defer span.End()
+
+	user, err := s.authorizeAction(ctx, actor, target)
+	if err != nil {
+		return err
+	}
+
+	return s.apply(ctx, actor, user, AuditActionUnsuspend, "", func(users *repository.UserRepository, _ *repository.SessionRepository) error {
+		return users.SetSuspended(ctx, user.Username, false)
+	})
+}
+
+func (s *AdminService) ForcePasswordReset(ctx context.Context, actor, target string) error {
+	ctx, span := tracer.Start(ctx, "AdminService.ForcePasswordReset")
+	defer span.End()
+
+	user, err := s.authorizeAction(ctx, actor, target)
+	if err != nil {
+		return err
+	}
+
+	return s.apply(ctx, actor, user, AuditActionPasswordReset, "", func(users *repository.UserRepository, sessions *repository.SessionRepository) error {
+		if err := users.SetPasswordResetRequired(ctx, user.Username, true); err != nil {
+			return err
+		}
+		return sessions.DeleteAllUserSessions(ctx, user.Username)
+	})
+}
+
+func (s *AdminService) SetRole(ctx context.Context, actor, target string, role models.Role) error {
+	ctx, span := tracer.Start(ctx, "AdminService.SetRole")
+	defer span.End()
+
+	user, err := s.authorizeAction(ctx, actor, target)
+	if err != nil {
+		return err
+	}
+
+	details := string(user.Role) + " -> " + string(role)
+	return s.apply(ctx, actor, user, AuditActionRoleChange, details, func(users *repository.UserRepository, _ *repository.SessionRepository) error {
+		return users.SetRole(ctx, user.Username, role)
+	})
+}
+
+func (s *AdminService) GetAuditHistory(ctx context.Context, target string, limit, offset int) ([]*models.AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.GetAuditHistory")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return s.auditRepo.ListForUser(ctx, user.ID, limit, offset)
+}
+
+// apply runs change and records it in the audit log in one transaction,
+// so that a privileged action never takes effect without its audit entry.
+func (s *AdminService) apply(
+	ctx context.Context,
+	actor string,
+	target *models.User,
+	action, details string,
+	change func(users *repository.UserRepository, sessions *repository.SessionRepository) error,
+) error {
+	return repository.InTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := change(s.repo.WithTx(tx), s.sessionRepo.WithTx(tx)); err != nil {
+			return err
+		}
+		return s.auditRepo.WithTx(tx).Record(ctx, actor, target, action, details)
+	})
+}
+
+// authorizeAction enforces that staff can't act on themselves and that
+// moderators can only act on regular users. It returns the target user.
+func (s *AdminService) authorizeAction(ctx context.Context, actor, target string) (*models.User, error) {
+	if actor == target {
+		return nil, errors.New("forbidden: cannot perform this action on your own account")
+	}
+
+	actorUser, err := s.repo.GetUserByUsername(ctx, actor)
+	if err != nil {
+		return nil, err
+	}
+	targetUser, err := s.repo.GetUserByUsername(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if actorUser.Role != models.RoleAdmin && roleRank[actorUser.Role] <= roleRank[targetUser.Role] {
+		return nil, errors.New("forbidden: insufficient role for target account")
+	}
+
+	return targetUser, nil
+}
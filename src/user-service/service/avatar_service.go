@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"social-network/user-service/media"
+	"social-network/user-service/models"
+	"social-network/user-service/repository"
+)
+
+const (
+	avatarLargeSuffix     = "-large.jpg"
+	avatarThumbnailSuffix = "-thumb.jpg"
+)
+
+type AvatarService struct {
+	repo  *repository.UserRepository
+	store media.BlobStore
+}
+
+func NewAvatarService(repo *repository.UserRepository, store media.BlobStore) *AvatarService {
+	return &AvatarService{
+		repo:  repo,
+		store: store,
+	}
+}
+
+// SetAvatar processes and stores a new avatar. Every upload gets a fresh
+// key so the blobs can be cached forever; the previous ones are removed.
+func (s *AvatarService) SetAvatar(ctx context.Context, username string, data []byte) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "AvatarService.SetAvatar")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := media.ProcessAvatar(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := "avatars/" + user.PublicID + "/" + uuid.New().String()
+	if err := s.store.Put(key+avatarLargeSuffix, bytes.NewReader(processed.Large), "image/jpeg"); err != nil {
+		return nil, err
+	}
+	if err := s.store.Put(key+avatarThumbnailSuffix, bytes.NewReader(processed.Thumbnail), "image/jpeg"); err != nil {
+		s.deleteBlobs(key)
+		return nil, err
+	}
+
+	url := s.store.URL(key + avatarLargeSuffix)
+	thumbnailURL := s.store.URL(key + avatarThumbnailSuffix)
+	if err := s.repo.UpdateAvatar(ctx, user.Username, key, url, thumbnailURL); err != nil {
+		s.deleteBlobs(key)
+		return nil, err
+	}
+
+	if user.AvatarKey != "" {
+		s.deleteBlobs(user.AvatarKey)
+	}
+
+	user.AvatarKey = key
+	user.AvatarURL = url
+	user.AvatarThumbnailURL = thumbnailURL
+	return user, nil
+}
+
+func (s *AvatarService) DeleteAvatar(ctx context.Context, username string) error {
+	ctx, span := tracer.Start(ctx, "AvatarService.DeleteAvatar")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if user.AvatarKey == "" {
+		return nil
+	}
+
+	if err := s.repo.UpdateAvatar(ctx, user.Username, "", "", ""); err != nil {
+		return err
+	}
+
+	s.deleteBlobs(user.AvatarKey)
+	return nil
+}
+
+// PurgeDeletedAvatars removes media of accounts past the deletion grace
+// period. It must run before UserService.PurgeDeletedAccounts drops the rows.
+func (s *AvatarService) PurgeDeletedAvatars(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "AvatarService.PurgeDeletedAvatars")
+	defer span.End()
+
+	keys, err := s.repo.ListPurgeableAvatarKeys(ctx, time.Now().Add(-AccountDeletionGracePeriod))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		s.deleteBlobs(key)
+	}
+	return nil
+}
+
+func (s *AvatarService) deleteBlobs(key string) {
+	for _, suffix := range []string{avatarLargeSuffix, avatarThumbnailSuffix} {
+		if err := s.store.Delete(key + suffix); err != nil {
+			slog.Warn("failed to delete blob", "key", key+suffix, "error", err)
+		}
+	}
+}
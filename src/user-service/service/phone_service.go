@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"social-network/user-service/models"
+	"social-network/user-service/phone"
+	"social-network/user-service/repository"
+	"social-network/user-service/sms"
+)
+
+type PhoneOptions struct {
+	// DefaultRegion resolves numbers entered without a country code.
+	DefaultRegion string
+	// RequireUnique rejects numbers another account has already verified.
+	RequireUnique bool
+}
+
+type PhoneService struct {
+	repo             *repository.UserRepository
+	verificationRepo *repository.PhoneVerificationRepository
+	sender           sms.Sender
+	options          PhoneOptions
+}
+
+func NewPhoneService(
+	repo *repository.UserRepository,
+	verificationRepo *repository.PhoneVerificationRepository,
+	sender sms.Sender,
+	options PhoneOptions,
+) *PhoneService {
+	return &PhoneService{
+		repo:             repo,
+		verificationRepo: verificationRepo,
+		sender:           sender,
+		options:          options,
+	}
+}
+
+// Normalize turns user input into E.164 and applies the uniqueness policy.
+func (s *PhoneService) Normalize(ctx context.Context, username, raw string) (string, error) {
+	ctx, span := tracer.Start(ctx, "PhoneService.Normalize")
+	defer span.End()
+
+	number, err := phone.Normalize(raw, s.options.DefaultRegion)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkUnique(ctx, username, number); err != nil {
+		return "", err
+	}
+
+	return number, nil
+}
+
+func (s *PhoneService) checkUnique(ctx context.Context, username, number string) error {
+	if !s.options.RequireUnique {
+		return nil
+	}
+
+	taken, err := s.repo.PhoneNumberVerifiedByOther(ctx, number, username)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("phone number already used by another account")
+	}
+	return nil
+}
+
+func (s *PhoneService) StartVerification(ctx context.Context, username string) (*models.PhoneVerificationResponse, error) {
+	ctx, span := tracer.Start(ctx, "PhoneService.StartVerification")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PhoneNumber == "" {
+		return nil, errors.New("no phone number set")
+	}
+	if user.PhoneVerifiedAt != nil {
+		return nil, errors.New("phone number already verified")
+	}
+	if err := s.checkUnique(ctx, username, user.PhoneNumber); err != nil {
+		return nil, err
+	}
+
+	pending, err := s.verificationRepo.Get(ctx, user.ID)
+	if err == nil && pending.PhoneNumber == user.PhoneNumber &&
+		time.Since(pending.CreatedAt) < models.PhoneCodeResendDelay {
+		return nil, errors.New("too many requests: wait before requesting another code")
+	}
+
+	code, err := models.GeneratePhoneCode()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	verification := &models.PhoneVerification{
+		UserID:      user.ID,
+		PhoneNumber: user.PhoneNumber,
+		CodeHash:    models.HashPhoneCode(code),
+		ExpiresAt:   now.Add(models.PhoneCodeTTL),
+		CreatedAt:   now,
+	}
+	if err := s.verificationRepo.Save(ctx, verification); err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Your verification code is %s", code)
+	if err := s.sender.Send(ctx, user.PhoneNumber, message); err != nil {
+		s.verificationRepo.Delete(ctx, user.ID)
+		return nil, fmt.Errorf("failed to send verification code: %w", err)
+	}
+
+	return &models.PhoneVerificationResponse{
+		Message:     "Verification code sent",
+		PhoneNumber: user.PhoneNumber,
+		ExpiresAt:   verification.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+func (s *PhoneService) ConfirmVerification(ctx context.Context, username, code string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "PhoneService.ConfirmVerification")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	verification, err := s.verificationRepo.Get(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if verification.PhoneNumber != user.PhoneNumber {
+		s.verificationRepo.Delete(ctx, user.ID)
+		return nil, errors.New("phone number changed since the code was sent")
+	}
+	if time.Now().After(verification.ExpiresAt) {
+		s.verificationRepo.Delete(ctx, user.ID)
+		return nil, errors.New("verification code expired")
+	}
+	if verification.Attempts >= models.PhoneCodeMaxAttempts {
+		s.verificationRepo.Delete(ctx, user.ID)
+		return nil, errors.New("too many attempts: request a new code")
+	}
+
+	if models.HashPhoneCode(code) != verification.CodeHash {
+		if err := s.verificationRepo.IncrementAttempts(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid verification code")
+	}
+
+	if err := s.checkUnique(ctx, username, user.PhoneNumber); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.MarkPhoneVerified(ctx, username, verification.PhoneNumber); err != nil {
+		return nil, err
+	}
+	if err := s.verificationRepo.Delete(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	// Re-read so updated_at, and with it the ETag, matches what was stored.
+	return s.repo.GetUserByUsername(ctx, username)
+}
+
+func (s *PhoneService) CleanExpiredVerifications(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "PhoneService.CleanExpiredVerifications")
+	defer span.End()
+
+	return s.verificationRepo.CleanExpired(ctx)
+}
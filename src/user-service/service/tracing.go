@@ -0,0 +1,7 @@
+package service
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts one span per exported service call; SQL spans created by
+// the instrumented driver nest under it.
+var tracer = otel.Tracer("social-network/user-service/service")
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"social-network/user-service/models"
+)
+
+const (
+	totpIssuer             = "SocialNetwork"
+	loginChallengeDuration = 5 * time.Minute
+	maxChallengeAttempts   = 5
+)
+
+func (s *UserService) createLoginChallenge(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	challenge, err := s.twoFactorRepo.CreateChallenge(ctx, user.ID, user.Username, loginChallengeDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Username:          user.Username,
+		Message:           "Two-factor authentication required",
+		ExpiresAt:         challenge.ExpiresAt.Format(time.RFC3339),
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge.ChallengeToken,
+	}, nil
+}
+
+func (s *UserService) VerifyTwoFactorLogin(ctx context.Context, req *models.TwoFactorLoginRequest) (*models.AuthResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.VerifyTwoFactorLogin")
+	defer span.End()
+
+	challenge, err := s.twoFactorRepo.GetChallengeByToken(ctx, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+		s.twoFactorRepo.DeleteChallenge(ctx, challenge.ChallengeToken)
+		return nil, errors.New("login challenge expired")
+	}
+
+	ok, err := s.checkSecondFactor(ctx, challenge.UserID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.twoFactorRepo.IncrementChallengeAttempts(ctx, challenge.ChallengeToken); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid two-factor code")
+	}
+
+	if err := s.twoFactorRepo.DeleteChallenge(ctx, challenge.ChallengeToken); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByUsername(ctx, challenge.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.createSession(ctx, user)
+}
+
+func (s *UserService) EnrollTwoFactor(ctx context.Context, username string) (*models.TOTPEnrollResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.EnrollTwoFactor")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.twoFactorRepo.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	secret, err := models.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.SaveSecret(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: models.TOTPURI(totpIssuer, user.Username, secret),
+	}, nil
+}
+
+func (s *UserService) ConfirmTwoFactor(ctx context.Context, username, code string) (*models.TOTPConfirmResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ConfirmTwoFactor")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := s.twoFactorRepo.GetSecret(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Confirmed {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	step, ok := models.ValidateTOTPCode(secret.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid two-factor code")
+	}
+
+	recoveryCodes, err := models.GenerateRecoveryCodes(models.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, recoveryCode := range recoveryCodes {
+		hashes = append(hashes, models.HashRecoveryCode(recoveryCode))
+	}
+
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.ConfirmSecret(ctx, user.ID, step); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPConfirmResponse{
+		Message:       "Two-factor authentication enabled",
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func (s *UserService) DisableTwoFactor(ctx context.Context, username, code string) error {
+	ctx, span := tracer.Start(ctx, "UserService.DisableTwoFactor")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	enabled, err := s.twoFactorRepo.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.New("two-factor authentication not enabled")
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user.ID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid two-factor code")
+	}
+
+	return s.twoFactorRepo.DeleteSecret(ctx, user.ID)
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused
+// recovery code, consuming whichever one matched.
+func (s *UserService) checkSecondFactor(ctx context.Context, userID int64, code string) (bool, error) {
+	secret, err := s.twoFactorRepo.GetSecret(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := models.ValidateTOTPCode(secret.Secret, code, time.Now()); ok {
+		return s.twoFactorRepo.MarkStepUsed(ctx, userID, step)
+	}
+
+	return s.twoFactorRepo.UseRecoveryCode(ctx, userID, models.HashRecoveryCode(code))
+}
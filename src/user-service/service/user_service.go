@@ -1,40 +1,56 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"social-network/user-service/models"
+	"social-network/user-service/password"
 	"social-network/user-service/repository"
 )
 
+// MaxBatchLookupSize caps usernames plus ids in one BatchLookupUsers call.
+const MaxBatchLookupSize = 100
+
 type UserService struct {
-	repo        *repository.UserRepository
-	sessionRepo *repository.SessionRepository
+	repo          *repository.UserRepository
+	sessionRepo   *repository.SessionRepository
+	twoFactorRepo *repository.TwoFactorRepository
+	auditRepo     *repository.AuditRepository
+	phones        *PhoneService
+	hasher        password.Hasher
+	sessionTTL    time.Duration
 }
 
-func NewUserService(repo *repository.UserRepository, sessionRepo *repository.SessionRepository) *UserService {
+func NewUserService(
+	repo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	twoFactorRepo *repository.TwoFactorRepository,
+	auditRepo *repository.AuditRepository,
+	phones *PhoneService,
+	hasher password.Hasher,
+	sessionTTL time.Duration,
+) *UserService {
 	return &UserService{
-		repo:        repo,
-		sessionRepo: sessionRepo,
+		repo:          repo,
+		sessionRepo:   sessionRepo,
+		twoFactorRepo: twoFactorRepo,
+		auditRepo:     auditRepo,
+		phones:        phones,
+		hasher:        hasher,
+		sessionTTL:    sessionTTL,
 	}
 }
 
-func (s *UserService) SignIn(req *models.SignInRequest) (*models.AuthResponse, error) {
-	fmt.Println(1)
-	if s == nil {
-		panic("s is nil")
-	}
-	if s.repo == nil {
-		panic("s.repo is nil")
-	}
-	if req == nil {
-		panic("req is nil")
-	}
+func (s *UserService) SignIn(ctx context.Context, req *models.SignInRequest) (*models.AuthResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.SignIn")
+	defer span.End()
 
-	exists, err := s.repo.UserExists(req.Username)
-	fmt.Println(2)
+	exists, err := s.repo.UserExists(ctx, req.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +58,7 @@ func (s *UserService) SignIn(req *models.SignInRequest) (*models.AuthResponse, e
 		return nil, errors.New("username already exists")
 	}
 
-	exists, err = s.repo.EmailExists(req.Email)
+	exists, err = s.repo.EmailExists(ctx, req.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +66,7 @@ func (s *UserService) SignIn(req *models.SignInRequest) (*models.AuthResponse, e
 		return nil, errors.New("email already exists")
 	}
 
-	hashedPassword, err := models.HashPassword(req.Password)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +79,7 @@ func (s *UserService) SignIn(req *models.SignInRequest) (*models.AuthResponse, e
 		UpdatedAt: time.Now(),
 	}
 
-	err = s.repo.CreateUser(user)
+	err = s.repo.CreateUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
@@ -74,13 +90,16 @@ func (s *UserService) SignIn(req *models.SignInRequest) (*models.AuthResponse, e
 	}, nil
 }
 
-func (s *UserService) Login(req *models.LoginRequest) (*models.AuthResponse, error) {
-	user, err := s.repo.GetUserByUsername(req.Username)
+func (s *UserService) Login(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, req.Username)
 	if err != nil {
 		return nil, err
 	}
 
-	if !models.CheckPasswordHash(req.Password, user.Password) {
+	if !s.checkPassword(ctx, user, req.Password) {
 		return nil, errors.New("invalid credentials")
 	}
 
@@ -90,85 +109,205 @@ func (s *UserService) Login(req *models.LoginRequest) (*models.AuthResponse, err
 	}, nil
 }
 
-func (s *UserService) GetUserFullProfile(username, password string) (*models.User, error) {
-	user, err := s.repo.GetUserByUsername(username)
+func (s *UserService) GetUserFullProfile(ctx context.Context, username, password string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserFullProfile")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
-	if !models.CheckPasswordHash(password, user.Password) {
+	if !s.checkPassword(ctx, user, password) {
 		return nil, errors.New("invalid credentials")
 	}
 
 	return user, nil
 }
 
-func (s *UserService) GetUserPublicProfile(username string) (*models.UserPublic, error) {
-	user, err := s.repo.GetUserByUsername(username)
+func (s *UserService) GetUserPublicProfile(ctx context.Context, username string) (*models.UserPublic, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserPublicProfile")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsernameFromReplica(ctx, username)
 	if err != nil {
 		return nil, err
 	}
+	if user.DeletedAt != nil || user.SuspendedAt != nil {
+		return nil, errors.New("user not found")
+	}
 
 	return &models.UserPublic{
-		Username: user.Username,
-		Name:     user.Name,
-		Surname:  user.Surname,
+		ID:                 user.PublicID,
+		Username:           user.Username,
+		Name:               user.Name,
+		Surname:            user.Surname,
+		Bio:                user.Bio,
+		AvatarURL:          user.AvatarURL,
+		AvatarThumbnailURL: user.AvatarThumbnailURL,
+	}, nil
+}
+
+// SearchUsers returns one page of the public directory. The cursor is the
+// last username of the previous page.
+func (s *UserService) SearchUsers(ctx context.Context, query, cursor string, limit int) (*models.UserSearchResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.SearchUsers")
+	defer span.End()
+
+	users, err := s.repo.SearchPublicUsers(ctx, query, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.UserSearchResponse{Users: users}
+	if len(users) > limit {
+		response.Users = users[:limit]
+		response.NextCursor = users[limit-1].Username
+	}
+
+	return response, nil
+}
+
+func (s *UserService) BatchLookupUsers(ctx context.Context, req *models.BatchUserLookupRequest) (*models.BatchUserLookupResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.BatchLookupUsers")
+	defer span.End()
+
+	if len(req.Usernames)+len(req.IDs) == 0 {
+		return nil, errors.New("at least one username or id is required")
+	}
+	if len(req.Usernames)+len(req.IDs) > MaxBatchLookupSize {
+		return nil, fmt.Errorf("at most %d usernames and ids can be requested at once", MaxBatchLookupSize)
+	}
+
+	users, err := s.repo.GetPublicUsersBatch(ctx, req.Usernames, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	foundUsernames := make(map[string]bool, len(users))
+	foundIDs := make(map[string]bool, len(users))
+	for _, user := range users {
+		foundUsernames[user.Username] = true
+		foundIDs[user.ID] = true
+	}
+
+	notFound := []string{}
+	for _, username := range req.Usernames {
+		if !foundUsernames[username] {
+			notFound = append(notFound, username)
+		}
+	}
+	for _, id := range req.IDs {
+		// Postgres renders UUIDs in lowercase.
+		if !foundIDs[strings.ToLower(id)] {
+			notFound = append(notFound, id)
+		}
+	}
+
+	return &models.BatchUserLookupResponse{
+		Users:    users,
+		NotFound: notFound,
 	}, nil
 }
 
-func (s *UserService) LoginWithSession(req *models.LoginRequest) (*models.AuthResponse, error) {
-	user, err := s.repo.GetUserByUsername(req.Username)
+func (s *UserService) LoginWithSession(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.LoginWithSession")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, req.Username)
 	if err != nil {
 		return nil, err
 	}
 
-	if !models.CheckPasswordHash(req.Password, user.Password) {
+	if !s.checkPassword(ctx, user, req.Password) {
 		return nil, errors.New("invalid credentials")
 	}
 
-	session, err := s.sessionRepo.CreateSession(user.ID, user.Username, 24*time.Hour)
+	if user.SuspendedAt != nil {
+		return nil, errors.New("account suspended")
+	}
+
+	enabled, err := s.twoFactorRepo.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return s.createLoginChallenge(ctx, user)
+	}
+
+	return s.createSession(ctx, user)
+}
+
+// createSession is reached only after full authentication, so it is also
+// where a pending account deletion gets cancelled.
+func (s *UserService) createSession(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	if user.DeletedAt != nil {
+		if err := s.repo.RestoreUser(ctx, user.Username); err != nil {
+			return nil, err
+		}
+		user.DeletedAt = nil
+	}
+
+	session, err := s.sessionRepo.CreateSession(ctx, user.ID, user.Username, s.sessionTTL)
 	if err != nil {
 		return nil, err
 	}
 	expiresFormatted := session.ExpiresAt.Format(time.RFC3339)
 
 	return &models.AuthResponse{
-		Username:  user.Username,
-		Message:   "Login successful",
-		Token:     session.SessionToken,
-		ExpiresAt: expiresFormatted,
+		Username:              user.Username,
+		Message:               "Login successful",
+		Token:                 session.SessionToken,
+		ExpiresAt:             expiresFormatted,
+		PasswordResetRequired: user.PasswordResetRequired,
 	}, nil
 }
 
-func (s *UserService) Logout(token string) error {
-	return s.sessionRepo.DeleteSession(token)
+func (s *UserService) SessionTTL() time.Duration {
+	return s.sessionTTL
+}
+
+func (s *UserService) Logout(ctx context.Context, token string) error {
+	ctx, span := tracer.Start(ctx, "UserService.Logout")
+	defer span.End()
+
+	return s.sessionRepo.DeleteSession(ctx, token)
 }
 
-func (s *UserService) ValidateSession(token string) (*models.Session, error) {
-	session, err := s.sessionRepo.GetSessionByToken(token)
+func (s *UserService) ValidateSession(ctx context.Context, token string) (*models.Session, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ValidateSession")
+	defer span.End()
+
+	session, err := s.sessionRepo.GetSessionByToken(ctx, token)
 	if err != nil {
 		return nil, err
 	}
 
 	if time.Now().After(session.ExpiresAt) {
-		s.sessionRepo.DeleteSession(token)
+		s.sessionRepo.DeleteSession(ctx, token)
 		return nil, errors.New("session expired")
 	}
 
 	return session, nil
 }
 
-func (s *UserService) GetUserBySession(token string) (*models.User, error) {
-	session, err := s.ValidateSession(token)
+func (s *UserService) GetUserBySession(ctx context.Context, token string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserBySession")
+	defer span.End()
+
+	session, err := s.ValidateSession(ctx, token)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.repo.GetUserByUsername(session.Username)
+	return s.repo.GetUserByUsername(ctx, session.Username)
 }
 
-func (s *UserService) UpdateUserProfileBySession(username string, req *models.UpdateUserRequest) (*models.User, error) {
-	user, err := s.repo.GetUserByUsername(username)
+func (s *UserService) UpdateUserProfileBySession(ctx context.Context, username string, req *models.UpdateUserRequest) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUserProfileBySession")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -180,7 +319,7 @@ func (s *UserService) UpdateUserProfileBySession(username string, req *models.Up
 		user.Surname = req.Surname
 	}
 	if req.Email != "" && req.Email != user.Email {
-		exists, err := s.repo.EmailExists(req.Email)
+		exists, err := s.repo.EmailExists(ctx, req.Email)
 		if err != nil {
 			return nil, err
 		}
@@ -190,41 +329,189 @@ func (s *UserService) UpdateUserProfileBySession(username string, req *models.Up
 		user.Email = req.Email
 	}
 	if req.PhoneNumber != "" {
-		user.PhoneNumber = req.PhoneNumber
+		if err := s.setPhoneNumber(ctx, user, req.PhoneNumber); err != nil {
+			return nil, err
+		}
 	}
-	if req.Birthdate != "" {
-		birthdate, err := time.Parse("2025-03-01", req.Birthdate)
-		if err != nil {
-			return nil, errors.New("invalid birthdate format")
+	if req.Bio != "" {
+		user.Bio = req.Bio
+	}
+	if req.Links != nil {
+		user.Links = req.Links
+	}
+	if req.Birthdate.Set {
+		user.Birthdate = req.Birthdate.NullDate
+	}
+
+	err = s.repo.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ChangePassword also signs the user out everywhere except the session the
+// request came from.
+func (s *UserService) ChangePassword(ctx context.Context, username, sessionToken string, req *models.ChangePasswordRequest) error {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if !s.checkPassword(ctx, user, req.CurrentPassword) {
+		return errors.New("invalid credentials")
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.Username, hashedPassword); err != nil {
+		return err
+	}
+
+	return s.sessionRepo.DeleteOtherUserSessions(ctx, user.Username, sessionToken)
+}
+
+// PatchUserProfile applies a merge patch. ifMatch is the raw If-Match
+// header; the write itself is also conditional on the updated_at that was
+// read, so concurrent edits are never silently overwritten.
+func (s *UserService) PatchUserProfile(ctx context.Context, username string, patch *models.UserPatchRequest, ifMatch string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.PatchUserProfile")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if ifMatch != "" && !models.ETagMatches(ifMatch, user.ETag()) {
+		return nil, errors.New("precondition failed: profile was modified")
+	}
+
+	if patch.Name.Set {
+		user.Name = patch.Name.Value
+	}
+	if patch.Surname.Set {
+		user.Surname = patch.Surname.Value
+	}
+	if patch.Email.Set {
+		if patch.Email.Null || patch.Email.Value == "" {
+			return nil, errors.New("email cannot be cleared")
+		}
+		if patch.Email.Value != user.Email {
+			exists, err := s.repo.EmailExists(ctx, patch.Email.Value)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				return nil, errors.New("email already used by another account")
+			}
+			user.Email = patch.Email.Value
+		}
+	}
+	if patch.Birthdate.Set {
+		user.Birthdate = patch.Birthdate.NullDate
+	}
+	if patch.PhoneNumber.Set {
+		if err := s.setPhoneNumber(ctx, user, patch.PhoneNumber.Value); err != nil {
+			return nil, err
 		}
-		user.Birthdate = birthdate
+	}
+	if patch.Bio.Set {
+		user.Bio = patch.Bio.Value
+	}
+	if patch.Links.Set {
+		user.Links = patch.Links.Value
 	}
 
-	err = s.repo.UpdateUser(user)
+	updated, err := s.repo.UpdateUserIfUnmodified(ctx, user, user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+	if !updated {
+		return nil, errors.New("precondition failed: profile was modified concurrently")
+	}
 
 	return user, nil
 }
 
-func (s *UserService) GetUserByUsername(username string) (*models.User, error) {
-	return s.repo.GetUserByUsername(username)
+// setPhoneNumber stores raw in E.164 form; an empty value clears the
+// number. Any change drops the verified status, mirroring the repository.
+func (s *UserService) setPhoneNumber(ctx context.Context, user *models.User, raw string) error {
+	number := ""
+	if raw != "" {
+		var err error
+		number, err = s.phones.Normalize(ctx, user.Username, raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	if number != user.PhoneNumber {
+		user.PhoneNumber = number
+		user.PhoneVerifiedAt = nil
+	}
+	return nil
+}
+
+// checkPassword verifies plain against the stored hash and, on success,
+// transparently upgrades hashes made with a weaker algorithm or cost. A
+// failed upgrade is logged and retried on the next login.
+func (s *UserService) checkPassword(ctx context.Context, user *models.User, plain string) bool {
+	if !s.hasher.Verify(plain, user.Password) {
+		return false
+	}
+
+	if s.hasher.NeedsRehash(user.Password) {
+		upgraded, err := s.hasher.Hash(plain)
+		if err == nil {
+			err = s.repo.ReplacePasswordHash(ctx, user.Username, user.Password, upgraded)
+		}
+		if err != nil {
+			slog.Warn("failed to upgrade password hash", "username", user.Username, "error", err)
+		} else {
+			user.Password = upgraded
+		}
+	}
+
+	return true
+}
+
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByUsername")
+	defer span.End()
+
+	return s.repo.GetUserByUsername(ctx, username)
 }
 
-func (s *UserService) ValidateCredentials(username, password string) (bool, error) {
-	user, err := s.repo.GetUserByUsername(username)
+func (s *UserService) ValidateCredentials(ctx context.Context, username, password string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ValidateCredentials")
+	defer span.End()
+
+	user, err := s.repo.GetUserByUsername(ctx, username)
 	if err != nil {
 		return false, err
 	}
 
-	return models.CheckPasswordHash(password, user.Password), nil
+	return s.checkPassword(ctx, user, password), nil
 }
 
-func (s *UserService) UserExists(username string) (bool, error) {
-	return s.repo.UserExists(username)
+func (s *UserService) UserExists(ctx context.Context, username string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "UserService.UserExists")
+	defer span.End()
+
+	return s.repo.UserExists(ctx, username)
 }
 
-func (s *UserService) EmailExists(email string) (bool, error) {
-	return s.repo.EmailExists(email)
+func (s *UserService) EmailExists(ctx context.Context, email string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "UserService.EmailExists")
+	defer span.End()
+
+	return s.repo.EmailExists(ctx, email)
 }
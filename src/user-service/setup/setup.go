@@ -0,0 +1,77 @@
+// Package setup turns config.UserService into the password, phone and SMS
+// components of the user service. It is shared by user-service and snctl
+// and keeps the config package free of service internals, so that other
+// binaries loading their own configuration don't link them.
+package setup
+
+import (
+	"errors"
+	"fmt"
+
+	"social-network/config"
+	"social-network/user-service/password"
+	"social-network/user-service/phone"
+	"social-network/user-service/sms"
+)
+
+// Check reports the settings only the service packages can judge, every
+// invalid one at once like config.UserService.Validate.
+func Check(cfg *config.UserService) error {
+	var problems []error
+	if !phone.IsKnownRegion(cfg.Phone.DefaultRegion) {
+		problems = append(problems, fmt.Errorf("phone.default_region %q is not supported", cfg.Phone.DefaultRegion))
+	}
+	if _, err := password.New(HasherConfig(cfg.Password)); err != nil {
+		problems = append(problems, fmt.Errorf("password: %w", err))
+	}
+	return errors.Join(problems...)
+}
+
+func HasherConfig(cfg config.PasswordConfig) password.Config {
+	return password.Config{
+		Algorithm:  cfg.HashAlgorithm,
+		BcryptCost: cfg.BcryptCost,
+		Argon2: password.Argon2Params{
+			Memory:      cfg.Argon2MemoryKB,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+		},
+	}
+}
+
+func PasswordHasher(cfg config.PasswordConfig) (*password.Manager, error) {
+	hasher, err := password.New(HasherConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hashing configuration: %w", err)
+	}
+	return hasher, nil
+}
+
+// PasswordPolicy builds the policy for new passwords, including the
+// breached list if one is configured. The length limit follows the hashing
+// algorithm.
+func PasswordPolicy(cfg config.PasswordConfig) (password.Policy, error) {
+	policy := password.DefaultPolicy
+	policy.MinLength = cfg.MinLength
+	policy.MinCharacterClasses = cfg.MinCharacterClasses
+	policy.ForbidPersonalInfo = cfg.ForbidPersonalInfo
+	policy.MaxBytes = HasherConfig(cfg).MaxPasswordBytes()
+
+	if cfg.BreachedListFile != "" {
+		breached, err := password.LoadBreachedList(cfg.BreachedListFile)
+		if err != nil {
+			return policy, fmt.Errorf("failed to load breached password list: %w", err)
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
+
+func SMSSender(cfg config.PhoneConfig) (sms.Sender, error) {
+	return sms.New(sms.Config{
+		Provider:     cfg.SMSProvider,
+		WebhookURL:   cfg.SMSWebhookURL,
+		WebhookToken: cfg.SMSWebhookToken,
+		Timeout:      cfg.SMSTimeout,
+	})
+}
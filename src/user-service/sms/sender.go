@@ -0,0 +1,73 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"social-network/logging"
+)
+
+// Sender delivers text messages to E.164 phone numbers. Production
+// deployments use a gateway; LogSender is meant for development.
+type Sender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// Providers accepted by New.
+const (
+	ProviderWebhook = "webhook"
+	ProviderLog     = "log"
+)
+
+type Config struct {
+	// Provider has no default, so that a deployment can't end up writing
+	// codes to the log by accident.
+	Provider     string
+	WebhookURL   string
+	WebhookToken string
+	Timeout      time.Duration
+}
+
+func New(cfg Config) (Sender, error) {
+	switch cfg.Provider {
+	case ProviderWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("the %s provider needs a webhook URL", ProviderWebhook)
+		}
+		return NewWebhookSender(cfg.WebhookURL, cfg.WebhookToken, cfg.Timeout), nil
+	case ProviderLog:
+		return NewLogSender(), nil
+	case "":
+		return nil, fmt.Errorf("no SMS provider configured; use %q, or %q in development", ProviderWebhook, ProviderLog)
+	default:
+		return nil, fmt.Errorf("unsupported SMS provider %q", cfg.Provider)
+	}
+}
+
+// LogSender writes messages to the service log instead of sending them, so
+// that one-time codes can be entered in development. It must be selected
+// explicitly, and each message is logged as a warning that says so; no
+// other sender or error path logs message text.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(ctx context.Context, to, message string) error {
+	logging.FromContext(ctx).Warn("sms not sent, logged for development only (SMS_PROVIDER=log)",
+		"to", MaskNumber(to), "message", message)
+	return nil
+}
+
+// MaskNumber hides all but the country code area and the last two digits
+// of a phone number, e.g. "+79991234567" becomes "+799******67".
+func MaskNumber(number string) string {
+	const visiblePrefix, visibleSuffix = 4, 2
+	if len(number) <= visiblePrefix+visibleSuffix {
+		return strings.Repeat("*", len(number))
+	}
+	return number[:visiblePrefix] + strings.Repeat("*", len(number)-visiblePrefix-visibleSuffix) + number[len(number)-visibleSuffix:]
+}
@@ -0,0 +1,69 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"social-network/logging"
+	"social-network/tracing"
+)
+
+// WebhookSender posts messages as JSON to an HTTP gateway:
+//
+//	{"to": "+79991234567", "message": "..."}
+//
+// Any 2xx response counts as accepted for delivery.
+type WebhookSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookSender returns a sender for the gateway at url. If token is not
+// empty it is sent as a bearer token.
+func NewWebhookSender(url, token string, timeout time.Duration) *WebhookSender {
+	return &WebhookSender{
+		url:   url,
+		token: token,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: tracing.Transport(http.DefaultTransport),
+		},
+	}
+}
+
+func (s *WebhookSender) Send(ctx context.Context, to, message string) error {
+	body, err := json.Marshal(map[string]string{"to": to, "message": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms gateway: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sms gateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}